@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Works with the plexbot configuration file",
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [config file]",
+	Short: "Checks the plexbot configuration file for problems",
+	Long: `Use this to check a configuration file before plexbot uses it.
+
+It checks that the file exists and parses, that the paths it points to exist
+and are writable, and that there are no unknown keys, malformed plugin commands
+or unknown tokens.
+
+Example:
+plexbot config validate c:\plexbot\plexbot.yaml`,
+	Args:          cobra.MaximumNArgs(1),
+	RunE:          validateConfig,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func validateConfig(cmd *cobra.Command, args []string) error {
+	//	Figure out which file to check
+	configFile := viper.ConfigFileUsed()
+	if len(args) > 0 {
+		configFile = args[0]
+	}
+
+	if configFile == "" {
+		return &ConfigError{Err: errors.New("couldn't find a plexbot config file to validate")}
+	}
+
+	problems := config.Validate(configFile)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if config.HasErrors(problems) {
+		return &ConfigError{Err: fmt.Errorf("%v has problems", configFile)}
+	}
+
+	fmt.Printf("%v is valid\n", configFile)
+	return nil
+}
+
+// loadConfig builds the typed configuration from viper's settings and runs
+// the same checks as 'config validate', apart from writing to the library
+// paths.  Warnings are logged, and errors stop the run before any files are
+// touched
+func loadConfig() (config.Config, error) {
+	//	Not having a config file is fine, but if there is one and we
+	//	couldn't read it, stop here rather than use the defaults:
+	if ProblemWithConfigFile && !configFileMissing() {
+		if path := viper.ConfigFileUsed(); path != "" {
+			for _, problem := range config.Check(path) {
+				log.Printf("[ERROR] %v", problem)
+			}
+		}
+		return config.Config{}, &ConfigError{Err: configFileError}
+	}
+
+	cfg, err := config.FromMap(viper.AllSettings())
+	if err != nil {
+		return cfg, &ConfigError{Err: err}
+	}
+
+	//	Check the config file if we have one, otherwise just the settings
+	var problems []config.Problem
+	if ProblemWithConfigFile {
+		log.Println("[WARN] No config file found -- using the default settings")
+		problems = cfg.Validate()
+	} else {
+		problems = config.Check(viper.ConfigFileUsed())
+	}
+
+	hasErrors := false
+	for _, problem := range problems {
+		//	A missing TV library has its own exit code, so let the move report it
+		if problem.Key == "plex.tvpath" {
+			continue
+		}
+
+		if problem.Severity == config.SeverityError {
+			hasErrors = true
+			log.Printf("[ERROR] %v", problem)
+		} else {
+			log.Printf("[WARN] %v", problem)
+		}
+	}
+
+	if hasErrors {
+		return cfg, &ConfigError{Err: errors.New("the configuration has problems")}
+	}
+
+	return cfg, nil
+}
+
+// configFileMissing returns true if the config file couldn't be read because
+// there isn't one in any of the places we look.  A config file passed with
+// --config (or PLEXBOT_CONFIG) has to exist
+func configFileMissing() bool {
+	var notFound viper.ConfigFileNotFoundError
+	return cfgFile == "" && errors.As(configFileError, &notFound)
+}
+
+func init() {
+	RootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
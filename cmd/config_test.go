@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// findConfig reads the config the way plexbot does at startup, from a
+// directory holding the given plexbot.yaml (or none, if it's empty)
+func findConfig(t *testing.T, contents string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if contents != "" {
+		if err := os.WriteFile(filepath.Join(dir, "plexbot.yaml"), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	t.Setenv("HOME", dir)
+	t.Setenv("PLEXBOT_CONFIG", "")
+
+	viper.Reset()
+	cfgFile, ProblemWithConfigFile, configFileError = "", false, nil
+	initConfig()
+}
+
+func TestLoadConfigUnreadableFile(t *testing.T) {
+	findConfig(t, "plex:\n  tvpath: /tv\n   errorpath: /errors\n")
+
+	_, err := loadConfig()
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("loadConfig error = %v, want a ConfigError", err)
+	}
+	if code, _ := exitCodeFor(err); code != ExitCodeConfig {
+		t.Errorf("exit code = %d, want %d", code, ExitCodeConfig)
+	}
+}
+
+func TestLoadConfigNoFile(t *testing.T) {
+	findConfig(t, "")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.Plex.TVPath == "" {
+		t.Error("the default TV path isn't set")
+	}
+}
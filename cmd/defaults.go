@@ -1,55 +1,61 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"runtime"
+	"strings"
 
+	"github.com/danesparza/plexbot/config"
+	toml "github.com/pelletier/go-toml"
 	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
 )
 
 var (
-	jsonConfig bool
-	yamlConfig bool
+	jsonConfig      bool
+	yamlConfig      bool
+	tomlConfig      bool
+	defaultPlatform string
 )
 
-var yamlDefault = []byte(`
-plex:
- tvpath: d:\tv
- errorpath: d:\errors
-
-# Token replacement for preprocess, postprocess and postprocessall sections:
-# {oldfilepath} - Replaced with full path of existing file in source directory
-# {newfilepath} - Replaced with full path of moved file in destination directory
-
-# To have a process run before the 'move' process, 
-# uncomment this section and add it here:
-# preprocess:
-#  - somecommand.exe {filepath}
-
-# To have a process run after the 'move' process, 
-# uncomment this section and add it here:
-postprocess:
-	- qbittorrentremove.exe -file "{oldfilepath}
-
-# To have a process run after all of the 'move' processes
-# uncomment this section and add it here
-# postprocessall:
-#  - someothercommand.exe {filepath}"
-`)
-
-var jsonDefault = []byte(`{
-  "plex": {
-		"tvpath": "d:\\tv",
-		"errorpath": "d:\\errors"
-  },
-	/*
-	Token replacement for preprocess, postprocess, and postprocessall sections:
-	{oldfilepath} - Replaced with full path of existing file in source directory
-	{newfilepath} - Replaced with full path of moved file in destination directory
-	*/
-  "postprocess": [
-    "qbittorrentremove.exe -file \"{oldfilepath}\""
-  ]
-}`)
+// defaultsComment documents the plugin sections.  It's included in the
+// formats that allow comments (JSON doesn't)
+var defaultsComment = `
+Token replacement for preprocess, postprocess and postprocessall sections:
+{oldfilepath} - Replaced with full path of existing file in source directory
+{newfilepath} - Replaced with full path of moved file in destination directory
+{tvpath} - Replaced with the Plex TV library path
+{errorpath} - Replaced with the errors path
+{hash} - Replaced with the torrent hash passed with --hash
+{showname} - Replaced with the name of the show
+{showseasonnumber} - Replaced with the season number (or aired year)
+{showepisodenumber} - Replaced with the episode number (or aired date)
+{runid} - Replaced with a unique ID for the run
+
+These tokens are only available in the postprocessall section.  Lists are
+separated with the path list separator (';' on Windows, ':' everywhere else):
+{movedfiles} - Replaced with the list of moved files
+{showfolders} - Replaced with the list of show folders that files were moved to
+{seasonfolders} - Replaced with the list of season folders that files were moved to
+{movedcount} - Replaced with the number of files moved
+{failedcount} - Replaced with the number of files that failed
+{skippedcount} - Replaced with the number of files skipped
+
+To have a process run before the 'move' process, add a preprocess section.
+To check or change where a file is going, add a premove section.  Premove hooks
+get the plan on stdin as JSON, and can reply on stdout with JSON to change it.
+To have a process run after each 'move' process, add a postprocess section.
+To have a process run after all of the 'move' processes, add a postprocessall section.
+
+Each token is also exported to plugins as an environment variable, like
+PLEXBOT_SHOWNAME or PLEXBOT_NEWFILEPATH.  To also get a JSON document describing
+the file and the run on stdin, write the plugin as a 'command' with 'stdin' set to 'json'.
+
+To send events to another service, add a webhooks section.  Each webhook has a
+url, and can have a method, headers, a body (a Go template), the events to send
+(file-moved, file-failed, parse-failed, run-completed), retries, a backoff and a
+secret used to sign the body.`
 
 // defaultsCmd represents the defaults command
 var defaultsCmd = &cobra.Command{
@@ -58,20 +64,110 @@ var defaultsCmd = &cobra.Command{
 	Long: `Use this to create a default configuration file for plexbot. 
 
 Example:
-plexbot defaults > plexbot.yaml`,
-	Run: func(cmd *cobra.Command, args []string) {
-		if jsonConfig {
-			fmt.Printf("%s", jsonDefault)
-		} else if yamlConfig {
-			fmt.Printf("%s", yamlDefault)
+plexbot defaults > plexbot.yaml
+plexbot defaults --toml --platform linux > plexbot.toml`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !contains(config.Platforms, defaultPlatform) {
+			return fmt.Errorf("Unknown platform %q (use %s)", defaultPlatform, strings.Join(config.Platforms, ", "))
+		}
+
+		defaults := config.Default(defaultPlatform)
+
+		var out []byte
+		var err error
+		switch {
+		case jsonConfig:
+			out, err = json.MarshalIndent(defaults, "", "  ")
+			out = append(out, '\n')
+		case tomlConfig:
+			out, err = marshalTOML(defaults)
+			out = append(commentLines(defaultsComment), out...)
+		default:
+			out, err = yaml.Marshal(defaults)
+			out = append(commentLines(defaultsComment), out...)
 		}
+
+		if err != nil {
+			return fmt.Errorf("Problem creating the default configuration: %v", err)
+		}
+
+		fmt.Printf("%s", out)
+		return nil
 	},
 }
 
+// marshalTOML formats the config as TOML.  It goes through JSON first,
+// so plugins that are just a command get written as a string
+func marshalTOML(cfg config.Config) ([]byte, error) {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &settings); err != nil {
+		return nil, err
+	}
+
+	tree, err := toml.TreeFromMap(integers(settings).(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(tree.String()), nil
+}
+
+// integers converts the whole numbers JSON gives us as floats back to integers
+func integers(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case float64:
+		if typed == float64(int64(typed)) {
+			return int64(typed)
+		}
+	case map[string]interface{}:
+		for k, v := range typed {
+			typed[k] = integers(v)
+		}
+	case []interface{}:
+		for i, v := range typed {
+			typed[i] = integers(v)
+		}
+	}
+	return value
+}
+
+// commentLines formats the text as a block of '#' comments
+// (which both YAML and TOML understand)
+func commentLines(text string) []byte {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		b.WriteString(strings.TrimSpace("# " + line))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+// contains returns true if the slice contains the item 'e'
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	RootCmd.AddCommand(defaultsCmd)
 
 	defaultsCmd.Flags().BoolVarP(&jsonConfig, "json", "j", false, "Create a JSON configuration file")
 	defaultsCmd.Flags().BoolVarP(&yamlConfig, "yaml", "y", true, "Create a YAML configuration file")
-
+	defaultsCmd.Flags().BoolVarP(&tomlConfig, "toml", "t", false, "Create a TOML configuration file")
+	//	Default to the paths for the platform we're running on
+	platform := runtime.GOOS
+	if !contains(config.Platforms, platform) {
+		platform = "linux"
+	}
+	defaultsCmd.Flags().StringVar(&defaultPlatform, "platform", platform, "Use the default paths for this platform (windows, linux or darwin)")
 }
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/danesparza/plexbot/mover"
+)
+
+// Exit codes used by plexbot.  Torrent clients and monitoring can use these
+// to tell what went wrong with a run
+const (
+	// ExitCodeTotalFailure means every file in the run failed to process
+	ExitCodeTotalFailure = 1
+
+	// ExitCodePartialFailure means some of the files in the run failed to process
+	ExitCodePartialFailure = 2
+
+	// ExitCodeConfig means there was a problem with the configuration
+	ExitCodeConfig = 3
+
+	// ExitCodeSourceMissing means the source directory doesn't exist
+	ExitCodeSourceMissing = 4
+
+	// ExitCodeDestinationMissing means the destination library directory doesn't exist
+	ExitCodeDestinationMissing = 5
+
+	// ExitCodeAborted means a premove hook stopped the run
+	ExitCodeAborted = 6
+)
+
+// ExitCoder is implemented by errors that know which exit code
+// plexbot should use when they are returned from a command
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// ConfigError indicates a problem with the configuration
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("There was a problem with the configuration: %v", e.Err)
+}
+
+// ExitCode returns the exit code for a configuration problem
+func (e *ConfigError) ExitCode() int { return ExitCodeConfig }
+
+// Unwrap returns the underlying configuration error
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// RunFailedError indicates that some or all of the files in a run failed
+type RunFailedError struct {
+	Failed int
+	Total  int
+}
+
+func (e *RunFailedError) Error() string {
+	return fmt.Sprintf("%d of %d file(s) failed to process", e.Failed, e.Total)
+}
+
+// ExitCode returns the total failure exit code if every file failed,
+// otherwise the partial failure exit code
+func (e *RunFailedError) ExitCode() int {
+	if e.Total > 0 && e.Failed >= e.Total {
+		return ExitCodeTotalFailure
+	}
+	return ExitCodePartialFailure
+}
+
+// exitCodeFor returns the exit code plexbot should use for the given error,
+// and whether the error has a specific exit code at all
+func exitCodeFor(err error) (int, bool) {
+	var exitErr ExitCoder
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+
+	var sourceErr *mover.SourceMissingError
+	if errors.As(err, &sourceErr) {
+		return ExitCodeSourceMissing, true
+	}
+
+	var destErr *mover.DestinationMissingError
+	if errors.As(err, &destErr) {
+		return ExitCodeDestinationMissing, true
+	}
+
+	var abortedErr *mover.AbortedError
+	if errors.As(err, &abortedErr) {
+		return ExitCodeAborted, true
+	}
+
+	return 0, false
+}
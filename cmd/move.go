@@ -1,28 +1,40 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"time"
 
-	"github.com/danesparza/dlshow"
 	"github.com/danesparza/plexbot/files"
-	"github.com/danesparza/plexbot/plugin"
+	"github.com/danesparza/plexbot/mover"
+	"github.com/danesparza/plexbot/report"
+	"github.com/danesparza/plexbot/torrent"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	sourceDirectory string
-	moveNoFile      = `You didn't pass anything to move.  
+	reportPath    string
+	reportCSVPath string
+	torrentFile   string
+	moveNoFile    = `You didn't pass anything to move.  
 
 Move requires a given directory to move from
 
 Example:
 plexbot move c:\source\dir`
+
+	//	Values that override what's parsed from the file names
+	moveShow    string
+	moveSeason  int
+	moveEpisode int
+	moveDate    string
+	moveLibrary string
+	moveMovie   bool
 )
 
 // moveCmd represents the move command
@@ -38,170 +50,216 @@ Source directory contains: 'Once.Upon.a.Time.S03E01.720p.HDTV.X264-DIMENSION.mkv
 Plex base TV directory: 'D:\TV'
 
 Then the file will get moved and renamed to:
-D:\TV\Once Upon a Time\Season 3\s3e01.mkv`,
-	Run: parseAndMove,
+D:\TV\Once Upon a Time\Season 3\s3e01.mkv
+
+If plexbot gets a name wrong, the show, season, episode or air date can be
+forced for the files with --show, --season, --episode and --date (files that
+didn't parse as episodes need both --season and --episode).  --library files
+them in another library, and --movie files them as movies (in the library,
+named by --show or their title and year).  They're then named and moved as
+usual, and plugins see the forced values.  --episode and --date can only be
+used when there's a single file to move.
+
+Example:
+plexbot move --show "Doctor Who (2005)" --season 2 c:\source\dir
+plexbot move --movie --library d:\Movies c:\source\dir`,
+	RunE:          parseAndMove,
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
-func parseAndMove(cmd *cobra.Command, args []string) {
-	//	If we have a config file, report it:
-	if viper.ConfigFileUsed() != "" {
-		log.Println("[INFO] Using config file:", viper.ConfigFileUsed())
+func parseAndMove(cmd *cobra.Command, args []string) error {
+	//	Make sure we were called with a directory
+	if len(args) < 1 {
+		return errors.New(moveNoFile)
 	}
 
-	//	Emit our plex tv directory
-	log.Printf("[INFO] Plex TV library path: %s\n", viper.GetString("plex.tvpath"))
-	log.Printf("[INFO] Errors path: %s\n", viper.GetString("plex.errorpath"))
+	overrides, err := moveOverrides(cmd)
+	if err != nil {
+		return err
+	}
 
-	//	Add the tv path to the list of tokens
-	tokens["{tvpath}"] = viper.GetString("plex.tvpath")
-	tokens["{errorpath}"] = viper.GetString("plex.errorpath")
+	opts := mover.Options{
+		SourceDir: args[0],
+		Hash:      hash,
+		Tags:      parseTags(taglist),
+		Overrides: overrides,
+	}
 
-	//	Indicate the tags that were passed to us
-	if len(taglist) > 0 {
-		log.Printf("[INFO] Tags passed: %s\n", taglist)
+	//	If we have the torrent, only process its files
+	if err := useTorrent(&opts); err != nil {
+		return err
+	}
+
+	return runMove(opts)
+}
+
+// moveOverrides returns the values passed to force on the files, or nil if
+// there aren't any
+func moveOverrides(cmd *cobra.Command) (*mover.Overrides, error) {
+	flags := cmd.Flags()
+	o := &mover.Overrides{ShowName: strings.TrimSpace(moveShow), Movie: moveMovie}
+
+	if flags.Changed("season") {
+		if moveSeason < 0 {
+			return nil, errors.New("the --season can't be less than zero")
+		}
+		o.SeasonNumber = &moveSeason
+	}
+	if flags.Changed("episode") {
+		if moveEpisode < 0 {
+			return nil, errors.New("the --episode can't be less than zero")
+		}
+		o.EpisodeNumber = &moveEpisode
 	}
 
-	//	If we have a 'noprocess' tag, indicate we found that and we're not going to continue
-	if strings.Contains(taglist, "noprocess") {
-		log.Println("[INFO] Found a 'noprocess' tag, so we won't be continuing to process this file")
-		return
+	if moveDate != "" {
+		aired, err := time.Parse("2006-01-02", moveDate)
+		if err != nil {
+			return nil, fmt.Errorf("the --date %q isn't a date like 2024-01-05", moveDate)
+		}
+		o.AiredYear, o.AiredMonth, o.AiredDay = aired.Year(), int(aired.Month()), aired.Day()
 	}
 
-	//	Make sure we were called with a directory
-	if len(args) < 1 {
-		fmt.Println(moveNoFile)
-		return
+	if moveLibrary != "" {
+		library, err := filepath.Abs(moveLibrary)
+		if err != nil {
+			return nil, err
+		}
+		o.Library = library
 	}
-	log.Printf("[INFO] Looking for files in: %v...", args[0])
 
-	//	See if the source directory exists
-	sourceBaseDir := args[0]
-	if _, err := os.Stat(sourceBaseDir); os.IsNotExist(err) {
-		log.Printf("[ERROR] The directory doesn't exist: %v", sourceBaseDir)
-		return
+	numbered := o.SeasonNumber != nil || o.EpisodeNumber != nil
+	switch {
+	case numbered && o.AiredYear != 0:
+		return nil, errors.New("pass --season and --episode, or --date, but not both")
+	case o.Movie && (numbered || o.AiredYear != 0):
+		return nil, errors.New("movies don't have a --season, --episode or --date")
+	case o.Movie && o.Library == "":
+		return nil, errors.New("pass the --library to file the movies in")
 	}
 
-	//	Get the errors directory
-	errorBaseDir := viper.GetString("plex.errorpath")
+	if *o == (mover.Overrides{}) {
+		return nil, nil
+	}
+	return o, nil
+}
 
-	//	See if the destination directory exists
-	destBaseDir := viper.GetString("plex.tvpath")
-	if _, err := os.Stat(destBaseDir); err != nil {
-		log.Printf("[ERROR] The plex TV directory doesn't exist: %v", destBaseDir)
-		return
+// useTorrent limits the run to the files in the torrent passed with
+// --torrent (or a matching .torrent file found next to the content).
+// The torrent also provides the hash, and its name is used if a
+// file name can't be parsed
+func useTorrent(opts *mover.Options) error {
+	var t *torrent.Torrent
+	if torrentFile != "" {
+		loaded, err := torrent.Load(torrentFile)
+		if err != nil {
+			return err
+		}
+		t = loaded
+	} else if t = torrent.Find(opts.SourceDir, opts.Hash); t == nil {
+		return nil
 	}
 
-	//	If it does, see what movie files it contains:
-	filesToMove := files.FindWithExtension([]string{".mp4", ".mkv", ".avi"}, sourceBaseDir)
-	log.Printf("[INFO] Found %d file(s) to process", len(filesToMove))
+	log.Printf("[INFO] Using torrent %v (%v) with %d file(s)", t.Name, t.InfoHash, len(t.Files))
 
-	for _, file := range filesToMove {
-		log.Printf("[INFO] - Found file %v...", file)
-		tokens["{oldfilepath}"] = file
+	if opts.Hash == "" {
+		opts.Hash = t.InfoHash
+	} else if !strings.EqualFold(opts.Hash, t.InfoHash) {
+		log.Printf("[WARN] The hash passed (%v) doesn't match the torrent's info hash (%v)", opts.Hash, t.InfoHash)
+	}
 
-		//	Perform preprocessing
-		if viper.InConfig("preprocess") {
-			preProcessItems := viper.GetStringSlice("preprocess")
-			for _, item := range preProcessItems {
-				item = plugin.FormatTokenizedString(item, tokens)
-				log.Printf("[INFO] -- Executing %v", item)
-				plugin.ExecutePlugin(item)
-			}
+	opts.Files = []string{}
+	missing := 0
+	for _, file := range t.Locate(opts.SourceDir) {
+		if !files.HasExtension(mover.MediaExtensions, file) {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			log.Printf("[WARN] %v is in the torrent, but wasn't found (it might not have been downloaded)", file)
+			missing++
+			continue
 		}
+		opts.Files = append(opts.Files, file)
+	}
 
-		//	Parse show information:
-		if showInfo, err := dlshow.GetEpisodeInfo(file); err == nil {
-
-			//	If we can't parse the filename,
-			//	we should move it to a safe place
-			if showInfo.ParseType == 0 {
-
-				//	Get just the filename we're trying to process:
-				_, currentFileName := filepath.Split(file)
-
-				//	Format the filename to tuck away to the errors directory:
-				errorFile := filepath.Join(errorBaseDir, currentFileName)
-
-				//	Make sure the errors path exists:
-				os.MkdirAll(errorBaseDir, os.ModePerm)
-
-				//	Copy the file to the error files path
-				if err := files.Copy(file, errorFile, os.ModePerm); err != nil {
-					log.Printf("[ERROR] %v", err)
-				}
-
-				//	Move to the next file...
-				continue
-			}
-
-			//	Add our showinfo tokens:
-			tokens["{showname}"] = properTitle(showInfo.ShowName)
-
-			//	Set the default file / path
-			newFile := "s0e0.information-not-found"
-			newPath := filepath.Join(destBaseDir, properTitle(showInfo.ShowName))
-
-			if showInfo.SeasonNumber == 0 && showInfo.EpisodeNumber == 0 && showInfo.AiredYear != 0 {
-				//	If we don't have season or episode, but have 'aired year'
-				//	just use the
-				tokens["{showseasonnumber}"] = strconv.Itoa(showInfo.AiredYear)
-				tokens["{showepisodenumber}"] = fmt.Sprintf("%v-%v-%v", showInfo.AiredYear, showInfo.AiredMonth, showInfo.AiredDay)
-
-				//	Format the new filepath:
-				seasonDir := fmt.Sprintf("Season %d", showInfo.AiredYear)
-				newPath = filepath.Join(destBaseDir, properTitle(showInfo.ShowName), seasonDir)
-				newFileName := fmt.Sprintf("%v %d-%02d-%02d%v", properTitle(showInfo.ShowName), showInfo.AiredYear, showInfo.AiredMonth, showInfo.AiredDay, filepath.Ext(file))
-				newFile = filepath.Join(newPath, newFileName)
-
-			} else {
-				//	We most likely have a traditional season/episode format
-				tokens["{showseasonnumber}"] = strconv.Itoa(showInfo.SeasonNumber)
-				tokens["{showepisodenumber}"] = strconv.Itoa(showInfo.EpisodeNumber)
-
-				//	Format the new filepath:
-				seasonDir := fmt.Sprintf("Season %d", showInfo.SeasonNumber)
-				newPath = filepath.Join(destBaseDir, properTitle(showInfo.ShowName), seasonDir)
-				newFileName := fmt.Sprintf("s%de%02d%v", showInfo.SeasonNumber, showInfo.EpisodeNumber, filepath.Ext(file))
-				newFile = filepath.Join(newPath, newFileName)
-			}
-
-			//	Add to our replacement tokens:
-			tokens["{newfilepath}"] = newFile
-
-			//	Make sure the new path exists:
-			os.MkdirAll(newPath, os.ModePerm)
-
-			//	Move the file
-			log.Printf("[INFO] -- Moving to %v", newFile)
-			if err := files.Copy(file, newFile, os.ModePerm); err != nil {
-				log.Printf("[ERROR] %v", err)
-			}
-
-			//	Perform 'postprocess each' items
-			if viper.InConfig("postprocess") {
-				postProcessItems := viper.GetStringSlice("postprocess")
-				for _, item := range postProcessItems {
-					item = plugin.FormatTokenizedString(item, tokens)
-					log.Printf("[INFO] -- Executing %v", item)
-					plugin.ExecutePlugin(item)
-				}
-			}
+	//	If we were told which torrent it is, its files should be there
+	if torrentFile != "" && missing > 0 && len(opts.Files) == 0 {
+		return &mover.SourceMissingError{Path: opts.SourceDir, Torrent: torrentFile}
+	}
 
-		}
+	opts.FallbackName = t.Name
+	return nil
+}
+
+// runMove loads the configuration, moves the files and writes the report.
+// It returns a RunFailedError if any of the files failed
+func runMove(opts mover.Options) error {
+	//	Load our configuration and make sure it's usable before we touch any files
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	//	If we have a config file, report it:
+	if viper.ConfigFileUsed() != "" {
+		log.Println("[INFO] Using config file:", viper.ConfigFileUsed())
+	}
+
+	//	Emit our plex tv directory
+	log.Printf("[INFO] Plex TV library path: %s\n", cfg.Plex.TVPath)
+	log.Printf("[INFO] Errors path: %s\n", cfg.Plex.ErrorPath)
+
+	//	Indicate the tags that were passed to us
+	if len(opts.Tags) > 0 {
+		log.Printf("[INFO] Tags passed: %s\n", strings.Join(opts.Tags, ","))
+	}
+
+	//	Move the files
+	runReport, err := mover.Run(cfg, opts)
+
+	//	Write the report -- even if the run stopped early
+	writeReport(runReport)
+	if err != nil {
+		return err
+	}
 
+	//	If anything failed, let the caller know:
+	if runReport.HasFailures() {
+		return &RunFailedError{Failed: runReport.FailedFiles(), Total: runReport.Totals.Files}
 	}
 
-	//	Perform 'postprocess all' items
-	if viper.InConfig("postprocessall") {
-		postProcessItems := viper.GetStringSlice("postprocessall")
-		for _, item := range postProcessItems {
-			item = plugin.FormatTokenizedString(item, tokens)
-			log.Printf("[INFO] -- Executing %v", item)
-			plugin.ExecutePlugin(item)
+	return nil
+}
+
+// writeReport writes the finished run report to any requested paths
+func writeReport(runReport *report.Report) {
+	t := runReport.Totals
+	log.Printf("[INFO] Processed %d file(s): %d moved, %d skipped, %d parse failed, %d error copied, %d failed", t.Files, t.Moved, t.Skipped, t.ParseFailed, t.ErrorCopied, t.Failed)
+
+	if reportPath != "" {
+		if err := runReport.WriteJSON(reportPath); err != nil {
+			log.Printf("[ERROR] Problem writing report to %v: %v", reportPath, err)
+		}
+	}
+
+	if reportCSVPath != "" {
+		if err := runReport.WriteCSV(reportCSVPath); err != nil {
+			log.Printf("[ERROR] Problem writing CSV report to %v: %v", reportCSVPath, err)
 		}
 	}
 }
 
 func init() {
 	RootCmd.AddCommand(moveCmd)
+
+	moveCmd.Flags().StringVar(&reportPath, "report", "", "Write a JSON report of the run to this path")
+	moveCmd.Flags().StringVar(&reportCSVPath, "report-csv", "", "Write a CSV report of the run to this path")
+	moveCmd.Flags().StringVar(&torrentFile, "torrent", "", "Only process the files in this .torrent file")
+	moveCmd.Flags().StringVar(&moveShow, "show", "", "Use this show name for the files (or title, with --movie)")
+	moveCmd.Flags().IntVar(&moveSeason, "season", 0, "Use this season for the files")
+	moveCmd.Flags().IntVar(&moveEpisode, "episode", 0, "Use this episode for the files")
+	moveCmd.Flags().StringVar(&moveDate, "date", "", "File the files by this air date (like 2024-01-05)")
+	moveCmd.Flags().StringVar(&moveLibrary, "library", "", "Put the files in the library at this path, instead of the Plex TV path")
+	moveCmd.Flags().BoolVar(&moveMovie, "movie", false, "File the files as movies (needs --library)")
 }
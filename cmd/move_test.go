@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/danesparza/plexbot/mover"
+)
+
+// passTorrent writes a single file torrent for the name, and passes it
+// with --torrent
+func passTorrent(t *testing.T, name string) {
+	t.Helper()
+
+	info := "d6:lengthi1e4:name" + strconv.Itoa(len(name)) + ":" + name + "12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaae"
+	path := filepath.Join(t.TempDir(), "download.torrent")
+	if err := os.WriteFile(path, []byte("d4:info"+info+"e"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := torrentFile
+	torrentFile = path
+	t.Cleanup(func() { torrentFile = previous })
+}
+
+func TestUseTorrent(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		create    bool
+		wantFiles bool
+		wantErr   bool
+	}{
+		{"file downloaded", "Show.Name.S01E02.mkv", true, true, false},
+		{"file missing", "Show.Name.S01E02.mkv", false, false, true},
+		{"no media files", "Show.Name.S01E02.nfo", false, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			passTorrent(t, test.file)
+			dir := t.TempDir()
+			file := filepath.Join(dir, test.file)
+			if test.create {
+				if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := mover.Options{SourceDir: dir}
+			err := useTorrent(&opts)
+
+			var missing *mover.SourceMissingError
+			switch {
+			case test.wantErr && !errors.As(err, &missing):
+				t.Fatalf("useTorrent = %v, want a SourceMissingError", err)
+			case test.wantErr:
+				if code, ok := exitCodeFor(err); !ok || code != ExitCodeSourceMissing {
+					t.Errorf("Exit code = %d, want %d", code, ExitCodeSourceMissing)
+				}
+			case err != nil:
+				t.Fatalf("useTorrent: %v", err)
+			}
+
+			want := []string{}
+			if test.wantFiles {
+				want = []string{file}
+			}
+			if !test.wantErr && !reflect.DeepEqual(opts.Files, want) {
+				t.Errorf("Files = %q, want %q", opts.Files, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/mover"
+	"github.com/danesparza/plexbot/parser"
+	"github.com/danesparza/plexbot/plugin"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	parseCorpus string
+	parseJSON   bool
+)
+
+// parseCmd represents the parse command
+var parseCmd = &cobra.Command{
+	Use:   "parse [name...]",
+	Short: "Shows how file names are parsed, without moving anything",
+	Long: `Use this to see how plexbot reads a file name and where it would move it.
+
+Each name is parsed with the parse rules and script from the config file, and
+plexbot prints what it found (the show, season and episode or air date, the
+quality, the confidence and the parser that matched) along with the
+destination.  Names can include the directories they're in.
+
+With --corpus, the names in a tab separated file are checked against what they
+should parse to, and any that don't match are reported.  Each line has the name,
+the parse type (se, date, extra or unknown), the show, the season, the episode,
+the air date (as YYYY-MM-DD) and the destination relative to the TV path
+('(errors)' for the errors path).  Trailing columns can be left off.
+
+Example:
+plexbot parse "Show.Name.S01E02.720p.HDTV.x264.mkv"
+plexbot parse --corpus releases.tsv`,
+	RunE:          parseNames,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+// parsedName is what 'plexbot parse --json' prints for each name
+type parsedName struct {
+	Name string `json:"name"`
+	*plugin.Plan
+}
+
+func parseNames(cmd *cobra.Command, args []string) error {
+	cfg, err := config.FromMap(viper.AllSettings())
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	if parseCorpus != "" {
+		return checkCorpus(cfg, parseCorpus)
+	}
+
+	if len(args) == 0 {
+		return errors.New("pass the names to parse, or a corpus with --corpus")
+	}
+
+	planner, err := mover.NewPlanner(cfg)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+	planner.Tags = parseTags(taglist)
+
+	var parsed []parsedName
+	for _, name := range args {
+		plan, err := planName(planner, name)
+		if err != nil {
+			return fmt.Errorf("problem parsing %v: %v", name, err)
+		}
+		parsed = append(parsed, parsedName{Name: name, Plan: plan})
+	}
+
+	if parseJSON {
+		out, err := json.MarshalIndent(parsed, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	for index, p := range parsed {
+		if index > 0 {
+			fmt.Println()
+		}
+		printParsed(p)
+	}
+	return nil
+}
+
+// printParsed prints what was parsed from a name
+func printParsed(p parsedName) {
+	fmt.Println(p.Name)
+
+	field := func(label string, value interface{}) {
+		fmt.Printf("  %-13s %v\n", label+":", value)
+	}
+
+	field("parser", orNone(p.Parser))
+	field("type", p.ParseType)
+	if p.ParseType != "unknown" {
+		field("show", p.ShowName)
+		if p.ShowYear != 0 {
+			field("year", p.ShowYear)
+		}
+		if p.ParseType == "extra" {
+			field("extra", p.Extra)
+		} else if p.ParseType == "date" {
+			field("aired", fmt.Sprintf("%d-%02d-%02d", p.AiredYear, p.AiredMonth, p.AiredDay))
+		} else {
+			field("season", p.SeasonNumber)
+			field("episode", p.EpisodeNumber)
+		}
+		if p.Title != "" {
+			field("title", p.Title)
+		}
+		field("confidence", fmt.Sprintf("%.2f", p.Confidence))
+	}
+	field("quality", orNone(p.Quality))
+	for _, alt := range p.Alternatives {
+		field("alternative", fmt.Sprintf("%v (%.2f)", parser.Describe(alt), alt.Confidence))
+	}
+	field("library", p.Library)
+	field("destination", p.Destination)
+	if p.Reason != "" {
+		field("reason", p.Reason)
+	}
+}
+
+// checkCorpus parses the names in a corpus file and reports the ones that
+// don't parse the way they should
+func checkCorpus(cfg config.Config, path string) error {
+	cases, err := parser.LoadCorpus(path)
+	if err != nil {
+		return err
+	}
+	if len(cases) == 0 {
+		return fmt.Errorf("%v doesn't have any names in it", path)
+	}
+
+	planner, err := corpusPlanner(cfg)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+	planner.Tags = parseTags(taglist)
+
+	failed := 0
+	for _, c := range cases {
+		if problems := checkCase(planner, c); len(problems) > 0 {
+			failed++
+			fmt.Printf("FAIL %v:%d: %v: %v\n", path, c.Line, c.Name, strings.Join(problems, ", "))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d name(s) didn't parse the way they should", failed, len(cases))
+	}
+
+	fmt.Printf("%d name(s) parsed the way they should\n", len(cases))
+	return nil
+}
+
+// corpusPlanner returns a planner for checking a corpus.  It uses
+// placeholder library paths, so the destinations don't depend on the host
+func corpusPlanner(cfg config.Config) (*mover.Planner, error) {
+	cfg.Plex = config.PlexConfig{TVPath: scriptTestTVPath, ErrorPath: scriptTestErrorPath}
+	return mover.NewPlanner(cfg)
+}
+
+// checkCase plans the move for a corpus name, and returns what's different
+// from what the corpus expects
+func checkCase(planner *mover.Planner, c parser.Case) []string {
+	plan, err := planName(planner, c.Name)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var problems []string
+	if !c.Matches(plan.ParseInfo) {
+		problems = append(problems, fmt.Sprintf("parsed as %v, want %v", parser.Describe(plan.ParseInfo), parser.Describe(c.Want)))
+	}
+	if c.Destination != "" {
+		got := describeDestination(relativeDestination(plan))
+		if want := filepath.ToSlash(c.Destination); got != want {
+			problems = append(problems, fmt.Sprintf("goes to %v, want %v", got, want))
+		}
+	}
+	return problems
+}
+
+// planName plans the move for a name, which can include the directories
+// it's in
+func planName(planner *mover.Planner, name string) (*plugin.Plan, error) {
+	name = filepath.FromSlash(name)
+	dirs := []string{}
+	if dir := filepath.Dir(name); dir != "." {
+		dirs = strings.Split(filepath.ToSlash(dir), "/")
+	}
+
+	return planner.Plan(name, dirs)
+}
+
+// orNone returns the value, or '(none)' if it's empty
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+func init() {
+	RootCmd.AddCommand(parseCmd)
+	parseCmd.Flags().StringVar(&parseCorpus, "corpus", "", "Check the names in this tab separated file against what they should parse to")
+	parseCmd.Flags().BoolVar(&parseJSON, "json", false, "Print the results as JSON")
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/parser"
+)
+
+func TestCorpus(t *testing.T) {
+	corpora := []struct {
+		path      string
+		dateOrder string
+	}{
+		{"../parser/testdata/releases.tsv", ""},
+		{"../parser/testdata/releases-mdy.tsv", parser.DateOrderMDY},
+	}
+
+	for _, corpus := range corpora {
+		cases, err := parser.LoadCorpus(corpus.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		planner, err := corpusPlanner(config.Config{DateOrder: corpus.dateOrder})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, c := range cases {
+			c := c
+			t.Run(filepath.Base(corpus.path)+"/"+c.Name, func(t *testing.T) {
+				for _, problem := range checkCase(planner, c) {
+					t.Errorf("line %d: %v", c.Line, problem)
+				}
+			})
+		}
+	}
+}
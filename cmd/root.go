@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 
+	"github.com/danesparza/plexbot/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -13,14 +17,11 @@ var (
 	cfgFile string
 	hash    string
 	taglist string
-	tags    []string
 
 	// ProblemWithConfigFile indicates whether or not there was a problem
 	// loading the config
 	ProblemWithConfigFile bool
-
-	//	Create our map of replacement tokens
-	tokens = make(map[string]string)
+	configFileError       error
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -33,8 +34,21 @@ and move it into the correct naming format for Plex.`,
 
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// If the command fails with an error that has an exit code, that code is used
 func Execute() {
+	//	See if we were started as a Usenet client's post-processing script
+	if args := usenetArgs(os.Args[1:]); args != nil {
+		RootCmd.SetArgs(args)
+	}
+
 	if err := RootCmd.Execute(); err != nil {
+		if code, ok := exitCodeFor(err); ok {
+			if err.Error() != "" {
+				log.Printf("[ERROR] %v", err)
+			}
+			os.Exit(code)
+		}
+
 		fmt.Println(err)
 		os.Exit(-1)
 	}
@@ -49,44 +63,48 @@ func init() {
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is plexbot.yaml)")
 	RootCmd.PersistentFlags().StringVar(&hash, "hash", "", "Torrent hash used to identify the torrent")
 	RootCmd.PersistentFlags().StringVar(&taglist, "tags", "", "List of tags (comma-seperated)")
-
-	//	Parse the string list of tags to a slice of tags:
-	tags = strings.Split(taglist, ",")
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	//	Set our defaults
-	viper.SetDefault("plex.tvpath", "d:\\tv")
-	viper.SetDefault("plex.errorpath", "d:\\errors")
-	viper.SetDefault("preprocess.command", []string{})
-	viper.SetDefault("postprocess.command", []string{})
+	defaults := config.Default(runtime.GOOS)
+	viper.SetDefault("plex.tvpath", defaults.Plex.TVPath)
+	viper.SetDefault("plex.errorpath", defaults.Plex.ErrorPath)
 
 	viper.SetConfigName("plexbot") // name of config file (without extension)
 	viper.AddConfigPath(".")       // adding current directory as search path
 	viper.AddConfigPath("$HOME")   // adding home directory as search path
 	viper.AutomaticEnv()           // read in environment variables that match
 
+	//	Download clients start plexbot without any flags, so the config
+	//	file can also be set with PLEXBOT_CONFIG, or live next to plexbot
+	if exe, err := os.Executable(); err == nil {
+		viper.AddConfigPath(filepath.Dir(exe))
+	}
+	if cfgFile == "" {
+		cfgFile = os.Getenv("PLEXBOT_CONFIG")
+	}
+
 	if cfgFile != "" { // enable ability to specify config file via flag
 		viper.SetConfigFile(cfgFile)
 	}
 
-	//	Set the hash token
-	tokens["{hash}"] = hash
-
 	// If a config file is found, read it in
 	// otherwise, make note that there was a problem
 	if err := viper.ReadInConfig(); err != nil {
 		ProblemWithConfigFile = true
+		configFileError = err
 	}
 }
 
-// properTitle returns the proper title case for a given string
-func properTitle(input string) string {
-	words := strings.Fields(input)
-
-	for index, word := range words {
-		words[index] = strings.Title(word)
+// parseTags parses the comma-separated list of tags to a slice of tags
+func parseTags(list string) []string {
+	var parsed []string
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			parsed = append(parsed, tag)
+		}
 	}
-	return strings.Join(words, " ")
+	return parsed
 }
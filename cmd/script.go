@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/mover"
+	"github.com/danesparza/plexbot/plugin"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var scriptParse string
+
+// The library paths used when testing scripts, so the results don't
+// depend on the host
+const (
+	scriptTestTVPath    = "tv"
+	scriptTestErrorPath = "errors"
+)
+
+// scriptCmd represents the script command
+var scriptCmd = &cobra.Command{
+	Use:   "script",
+	Short: "Works with parse and naming scripts",
+}
+
+// scriptTestCmd represents the script test command
+var scriptTestCmd = &cobra.Command{
+	Use:   "test [script] [file...]",
+	Short: "Runs the tests in a parse and naming script",
+	Long: `Use this to check a script before plexbot uses it.
+
+Without any files, it runs the tests defined in the script's 'tests' list and
+reports any that don't match.  With files, it prints where each one would go.
+Destinations are relative to the Plex TV path, and files that can't be parsed
+go to the errors path.  Nothing on disk is touched.
+
+If the script isn't given, the one in the config file is used.
+
+Example:
+plexbot script test rules.star
+plexbot script test rules.star "Show Name/Season 1/Episode 02.mkv"`,
+	RunE:          testScript,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func testScript(cmd *cobra.Command, args []string) error {
+	cfg := config.Config{Plex: config.PlexConfig{TVPath: scriptTestTVPath, ErrorPath: scriptTestErrorPath}}
+
+	//	Figure out which script to test
+	if len(args) > 0 {
+		cfg.Script = &config.ScriptConfig{Path: args[0], Parse: scriptParse}
+		args = args[1:]
+	} else if current, err := config.FromMap(viper.AllSettings()); err == nil && current.Script != nil {
+		cfg.Script = current.Script
+		if scriptParse != "" {
+			cfg.Script.Parse = scriptParse
+		}
+	}
+
+	if cfg.Script == nil || cfg.Script.Path == "" {
+		return &ConfigError{Err: errors.New("there's no script to test")}
+	}
+
+	planner, err := mover.NewPlanner(cfg)
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	//	Print where each of the files would go
+	if len(args) > 0 {
+		for _, file := range args {
+			got, err := planDestination(planner, file)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%v -> %v\n", file, describeDestination(got))
+		}
+		return nil
+	}
+
+	tests, err := planner.Script().Tests()
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+	if len(tests) == 0 {
+		return fmt.Errorf("%v doesn't define any tests", cfg.Script.Path)
+	}
+
+	failed := 0
+	for _, test := range tests {
+		got, err := planDestination(planner, test.File)
+		want := filepath.FromSlash(test.Want)
+
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("FAIL %v: %v\n", test.File, err)
+		case got != want:
+			failed++
+			fmt.Printf("FAIL %v -> %v, want %v\n", test.File, describeDestination(got), describeDestination(want))
+		default:
+			fmt.Printf("ok   %v -> %v\n", test.File, describeDestination(got))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d script test(s) failed", failed, len(tests))
+	}
+
+	fmt.Printf("%d script test(s) passed\n", len(tests))
+	return nil
+}
+
+// planDestination returns where the planner would send the file, relative to
+// the test TV path.  It's empty if the file would go to the errors path
+func planDestination(planner *mover.Planner, file string) (string, error) {
+	plan, err := planName(planner, file)
+	if err != nil {
+		return "", err
+	}
+	return relativeDestination(plan), nil
+}
+
+// relativeDestination returns the plan's destination relative to the test
+// TV path.  It's empty if the file is going to the errors path
+func relativeDestination(plan *plugin.Plan) string {
+	if plan.Library != "tv" {
+		return ""
+	}
+
+	rel, err := filepath.Rel(scriptTestTVPath, plan.Destination)
+	if err != nil {
+		return plan.Destination
+	}
+	return rel
+}
+
+// describeDestination formats a destination for the test output
+func describeDestination(destination string) string {
+	if destination == "" {
+		return "(errors)"
+	}
+	return filepath.ToSlash(destination)
+}
+
+func init() {
+	RootCmd.AddCommand(scriptCmd)
+	scriptCmd.AddCommand(scriptTestCmd)
+	scriptTestCmd.Flags().StringVar(&scriptParse, "parse", "", "When the script's parse function runs: before or instead (defaults to the config setting, or before)")
+}
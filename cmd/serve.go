@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/mover"
+	"github.com/danesparza/plexbot/receiver"
+	"github.com/danesparza/plexbot/report"
+	"github.com/spf13/cobra"
+)
+
+var serveListen string
+
+// defaultListen is the address 'plexbot serve' listens on if it isn't set
+const defaultListen = "127.0.0.1:8090"
+
+// The server timeouts.  Imports copy the whole file before replying, so
+// the write timeout has to allow for large files on slow disks
+const (
+	serveReadTimeout  = 30 * time.Second
+	serveWriteTimeout = time.Hour
+	serveIdleTimeout  = 2 * time.Minute
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Receives Sonarr and Radarr import webhooks",
+	Long: `Use this to make plexbot an import target for tools that send
+Sonarr "On Import" webhooks.
+
+Point the Sonarr webhook at http://host:8090/sonarr.  Each imported file is
+moved into the library using the series, season and episode from the webhook
+instead of parsing the file name.  Imports get a 'sonarr' tag, so plugin
+conditions can tell them apart.
+
+Radarr "On Import" webhooks can be sent to http://host:8090/radarr.  Movies
+are filed in a 'Title (Year)' folder in the server moviepath, and get a
+'radarr' tag.
+
+The download directories files can be imported from have to be listed in the
+server section of the config file (as roots).  plexbot listens on 127.0.0.1
+unless the listen address is set, and needs a username and password to listen
+on anything else.
+
+Example:
+plexbot serve --listen 127.0.0.1:8090`,
+	RunE:          serve,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func serve(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	server := config.ServerConfig{}
+	if cfg.Server != nil {
+		server = *cfg.Server
+	}
+	if serveListen != "" {
+		server.Listen = serveListen
+	}
+	if server.Listen == "" {
+		server.Listen = defaultListen
+	}
+
+	if len(server.Roots) == 0 {
+		return &ConfigError{Err: errors.New("list the download directories to import from in server.roots")}
+	}
+	if !loopback(server.Listen) && (server.Username == "" || server.Password == "") {
+		return &ConfigError{Err: fmt.Errorf("set server.username and server.password to listen on %v (or listen on 127.0.0.1)", server.Listen)}
+	}
+
+	handler := &receiver.Handler{
+		Username: server.Username,
+		Password: server.Password,
+		Roots:    server.Roots,
+		Import: func(imp receiver.Import) (*report.Report, error) {
+			metadata := imp.Metadata
+
+			//	Movies are filed in their own library
+			var overrides *mover.Overrides
+			if metadata.ParseType == "movie" {
+				if server.MoviePath == "" {
+					return nil, errors.New("set server.moviepath to import movies from Radarr")
+				}
+				overrides = &mover.Overrides{Library: server.MoviePath}
+			}
+
+			runReport, err := mover.Run(cfg, mover.Options{
+				SourceDir: filepath.Dir(imp.Path),
+				Files:     []string{imp.Path},
+				Hash:      imp.Hash,
+				Tags:      append(parseTags(taglist), imp.Source),
+				Metadata:  &metadata,
+				Overrides: overrides,
+			})
+			writeReport(runReport)
+			return runReport, err
+		},
+	}
+
+	httpServer := &http.Server{
+		Addr:              server.Listen,
+		Handler:           handler,
+		ReadHeaderTimeout: serveReadTimeout,
+		ReadTimeout:       serveReadTimeout,
+		WriteTimeout:      serveWriteTimeout,
+		IdleTimeout:       serveIdleTimeout,
+	}
+
+	log.Printf("[INFO] Listening for webhooks on %v", server.Listen)
+	return httpServer.ListenAndServe()
+}
+
+// loopback returns true if the address only listens on the loopback
+// interface, like 127.0.0.1:8090 or localhost:8090
+func loopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveListen, "listen", "", "The address to listen on (defaults to the config setting, or 127.0.0.1:8090)")
+	serveCmd.Flags().StringVar(&reportPath, "report", "", "Write a JSON report of each import to this path")
+	serveCmd.Flags().StringVar(&reportCSVPath, "report-csv", "", "Write a CSV report of each import to this path")
+}
@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danesparza/plexbot/mover"
+	"github.com/hashicorp/logutils"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes NZBGet expects from post-processing scripts
+const (
+	nzbgetSuccess = 93
+	nzbgetError   = 94
+	nzbgetNone    = 95
+)
+
+// Exit codes SABnzbd expects from post-processing scripts
+const (
+	sabnzbdSuccess = 0
+	sabnzbdError   = 1
+)
+
+// download describes a finished Usenet download
+type download struct {
+	Dir      string
+	Name     string
+	ID       string
+	Category string
+
+	//	Set if the client couldn't finish the download
+	Failed bool
+	Status string
+}
+
+// clientExit carries the exit code a Usenet client expects.  It's used
+// on success too, since NZBGet doesn't treat 0 as success
+type clientExit struct {
+	code int
+	err  error
+}
+
+func (e *clientExit) Error() string {
+	if e.err == nil {
+		return ""
+	}
+	return e.err.Error()
+}
+
+// ExitCode returns the exit code the client expects
+func (e *clientExit) ExitCode() int { return e.code }
+
+// sabnzbdCmd represents the sabnzbd command
+var sabnzbdCmd = &cobra.Command{
+	Use:   "sabnzbd [directory] [nzb name] [job name] [report number] [category] [group] [status]",
+	Short: "Runs plexbot as a SABnzbd post-processing script",
+	Long: `SABnzbd passes the download to its post-processing scripts as arguments
+and SAB_* environment variables.  The download's directory is moved like
+'plexbot move', its category is used as the tags, and failed downloads are
+skipped.  plexbot exits with 0 if everything worked, and 1 if it didn't (or
+the download failed).
+
+plexbot runs this automatically when it's started by SABnzbd without a
+command.  To use a wrapper script instead:
+plexbot --config /etc/plexbot/plexbot.yaml sabnzbd -- "$@"`,
+	RunE:          sabnzbdPostProcess,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+// nzbgetCmd represents the nzbget command
+var nzbgetCmd = &cobra.Command{
+	Use:   "nzbget",
+	Short: "Runs plexbot as an NZBGet post-processing script",
+	Long: `NZBGet passes the download to its post-processing scripts as NZBPP_*
+environment variables.  The download's directory is moved like 'plexbot move',
+its category is used as the tags, and failed downloads are skipped.  plexbot
+exits with 93 if everything worked, 94 if it didn't, and 95 if the download
+was skipped.
+
+NZBGet only runs scripts with a header, so use a wrapper script:
+#!/bin/sh
+### NZBGET POST-PROCESSING SCRIPT ###
+# Moves TV episodes into Plex with plexbot.
+### NZBGET POST-PROCESSING SCRIPT ###
+exec plexbot --config /etc/plexbot/plexbot.yaml nzbget`,
+	Args:          cobra.NoArgs,
+	RunE:          nzbgetPostProcess,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func sabnzbdPostProcess(cmd *cobra.Command, args []string) error {
+	d := sabnzbdDownload(args)
+
+	if d.Dir == "" {
+		return &clientExit{code: sabnzbdError, err: fmt.Errorf("SABnzbd didn't pass a download directory")}
+	}
+
+	//	SABnzbd shows the last line of output, so finish with a summary
+	if d.Failed {
+		fmt.Printf("Skipped %v: the download failed (status %v)\n", d.Name, d.Status)
+		return &clientExit{code: sabnzbdError, err: fmt.Errorf("the download failed (status %v)", d.Status)}
+	}
+
+	if err := runMove(usenetOptions(d)); err != nil {
+		fmt.Printf("plexbot couldn't process %v: %v\n", d.Name, err)
+		return &clientExit{code: sabnzbdError, err: err}
+	}
+
+	fmt.Printf("Moved %v into Plex\n", d.Name)
+	return &clientExit{code: sabnzbdSuccess}
+}
+
+func nzbgetPostProcess(cmd *cobra.Command, args []string) error {
+	nzbgetLog(os.Stdout)
+
+	d := nzbgetDownload()
+
+	if d.Dir == "" {
+		return &clientExit{code: nzbgetError, err: fmt.Errorf("NZBGet didn't pass a download directory")}
+	}
+
+	if d.Failed {
+		log.Printf("[WARN] Skipped %v: the download failed (status %v)", d.Name, d.Status)
+		return &clientExit{code: nzbgetNone}
+	}
+
+	if err := runMove(usenetOptions(d)); err != nil {
+		return &clientExit{code: nzbgetError, err: err}
+	}
+
+	return &clientExit{code: nzbgetSuccess}
+}
+
+// nzbgetLog sends the log to NZBGet, which reads the message type from the
+// start of each line.  The log levels are still filtered
+func nzbgetLog(out io.Writer) {
+	writer := nzbgetWriter{out: out}
+	if filter, ok := log.Writer().(*logutils.LevelFilter); ok {
+		filter.Writer = writer
+	} else {
+		log.SetOutput(writer)
+	}
+	log.SetFlags(0)
+}
+
+// nzbgetWriter writes log lines with the message types NZBGet knows
+type nzbgetWriter struct {
+	out io.Writer
+}
+
+func (w nzbgetWriter) Write(p []byte) (int, error) {
+	line := p
+	if bytes.HasPrefix(line, []byte("[WARN]")) {
+		line = append([]byte("[WARNING]"), line[len("[WARN]"):]...)
+	}
+
+	if _, err := w.out.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// sabnzbdDownload reads the download from SABnzbd's arguments, falling
+// back to its environment variables
+func sabnzbdDownload(args []string) download {
+	arg := func(index int, env string) string {
+		if index < len(args) && args[index] != "" {
+			return args[index]
+		}
+		return os.Getenv(env)
+	}
+
+	d := download{
+		Dir:      arg(0, "SAB_COMPLETE_DIR"),
+		Name:     arg(2, "SAB_FINAL_NAME"),
+		Category: arg(4, "SAB_CAT"),
+		Status:   arg(6, "SAB_PP_STATUS"),
+		ID:       os.Getenv("SAB_NZO_ID"),
+	}
+
+	if d.Name == "" {
+		d.Name = arg(1, "SAB_FILENAME")
+	}
+	if d.Name == "" {
+		d.Name = filepath.Base(d.Dir)
+	}
+
+	//	0 means everything worked.  1 and 2 are failed verification or
+	//	unpacking (3 is both), and -1 is a failed post-processing step
+	if d.Status != "" && d.Status != "0" {
+		d.Failed = true
+	}
+	if strings.EqualFold(os.Getenv("SAB_STATUS"), "Failed") {
+		d.Failed = true
+		d.Status = os.Getenv("SAB_STATUS")
+	}
+
+	return d
+}
+
+// nzbgetDownload reads the download from NZBGet's environment variables
+func nzbgetDownload() download {
+	d := download{
+		Dir:      os.Getenv("NZBPP_DIRECTORY"),
+		Name:     os.Getenv("NZBPP_NZBNAME"),
+		Category: os.Getenv("NZBPP_CATEGORY"),
+		ID:       os.Getenv("NZBPP_NZBID"),
+		Status:   os.Getenv("NZBPP_TOTALSTATUS"),
+	}
+
+	if d.Name == "" {
+		d.Name = filepath.Base(d.Dir)
+	}
+
+	switch strings.ToUpper(d.Status) {
+	case "FAILURE", "DELETED":
+		d.Failed = true
+	case "":
+		//	Older versions report the par and unpack status separately
+		//	(1 means it failed)
+		d.Status = fmt.Sprintf("par %v, unpack %v", os.Getenv("NZBPP_PARSTATUS"), os.Getenv("NZBPP_UNPACKSTATUS"))
+		d.Failed = os.Getenv("NZBPP_PARSTATUS") == "1" || os.Getenv("NZBPP_UNPACKSTATUS") == "1"
+	}
+
+	return d
+}
+
+// usenetOptions returns the move options for a download.  The category is
+// used as the tags, and the client's job ID as the hash
+func usenetOptions(d download) mover.Options {
+	tags := parseTags(d.Category)
+	if taglist != "" {
+		tags = append(tags, parseTags(taglist)...)
+	}
+
+	id := d.ID
+	if hash != "" {
+		id = hash
+	}
+
+	return mover.Options{
+		SourceDir: d.Dir,
+		Hash:      id,
+		Tags:      tags,
+	}
+}
+
+// usenetArgs returns the arguments to use if plexbot was started by a Usenet
+// client as its post-processing script, rather than with a command
+func usenetArgs(args []string) []string {
+	if len(args) > 0 {
+		if found, _, err := RootCmd.Find(args); err == nil && found != RootCmd {
+			return nil
+		}
+	}
+
+	switch {
+	case os.Getenv("NZBPP_DIRECTORY") != "":
+		return []string{"nzbget"}
+	case os.Getenv("SAB_COMPLETE_DIR") != "" || os.Getenv("SAB_VERSION") != "":
+		//	SABnzbd passes -1 as the status of a failed job, which
+		//	would otherwise look like a flag
+		return append([]string{"sabnzbd", "--"}, args...)
+	}
+
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(sabnzbdCmd)
+	RootCmd.AddCommand(nzbgetCmd)
+
+	for _, c := range []*cobra.Command{sabnzbdCmd, nzbgetCmd} {
+		c.Flags().StringVar(&reportPath, "report", "", "Write a JSON report of the run to this path")
+		c.Flags().StringVar(&reportCSVPath, "report-csv", "", "Write a CSV report of the run to this path")
+	}
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/logutils"
+)
+
+func TestNZBGetLog(t *testing.T) {
+	output, flags := log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		log.SetOutput(output)
+		log.SetFlags(flags)
+	})
+
+	//	The log is set up the way main does it
+	log.SetOutput(&logutils.LevelFilter{
+		Levels:   []logutils.LogLevel{"DEBUG", "INFO", "WARN", "ERROR"},
+		MinLevel: logutils.LogLevel("INFO"),
+		Writer:   os.Stderr,
+	})
+
+	var out bytes.Buffer
+	nzbgetLog(&out)
+
+	log.Printf("[DEBUG] Not shown")
+	log.Printf("[INFO] Looking for files")
+	log.Printf("[WARN] Skipped Show.Name.S01E02")
+	log.Printf("[ERROR] Couldn't move [WARN] file")
+
+	want := "[INFO] Looking for files\n[WARNING] Skipped Show.Name.S01E02\n[ERROR] Couldn't move [WARN] file\n"
+	if got := out.String(); got != want {
+		t.Errorf("Log = %q, want %q", got, want)
+	}
+}
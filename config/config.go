@@ -0,0 +1,373 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danesparza/plexbot/parser"
+	"github.com/danesparza/plexbot/webhook"
+	"github.com/mitchellh/mapstructure"
+)
+
+// CurrentVersion is the version of the config layout this build understands.
+// Older layouts are migrated when they're loaded
+const CurrentVersion = 2
+
+// Config is the plexbot configuration
+type Config struct {
+	Version        int             `yaml:"version" json:"version" toml:"version" mapstructure:"version"`
+	Plex           PlexConfig      `yaml:"plex" json:"plex" toml:"plex" mapstructure:"plex"`
+	Script         *ScriptConfig   `yaml:"script,omitempty" json:"script,omitempty" toml:"script,omitempty" mapstructure:"script"`
+	DateOrder      string          `yaml:"dateorder,omitempty" json:"dateorder,omitempty" toml:"dateorder,omitempty" mapstructure:"dateorder"`
+	Parsers        []ParseRule     `yaml:"parsers,omitempty" json:"parsers,omitempty" toml:"parsers,omitempty" mapstructure:"parsers"`
+	MinConfidence  float64         `yaml:"minconfidence,omitempty" json:"minconfidence,omitempty" toml:"minconfidence,omitempty" mapstructure:"minconfidence"`
+	Shows          map[string]Show `yaml:"shows,omitempty" json:"shows,omitempty" toml:"shows,omitempty" mapstructure:"shows"`
+	PreProcess     []Plugin        `yaml:"preprocess,omitempty" json:"preprocess,omitempty" toml:"preprocess,omitempty" mapstructure:"preprocess"`
+	PreMove        []Plugin        `yaml:"premove,omitempty" json:"premove,omitempty" toml:"premove,omitempty" mapstructure:"premove"`
+	PostProcess    []Plugin        `yaml:"postprocess,omitempty" json:"postprocess,omitempty" toml:"postprocess,omitempty" mapstructure:"postprocess"`
+	PostProcessAll []Plugin        `yaml:"postprocessall,omitempty" json:"postprocessall,omitempty" toml:"postprocessall,omitempty" mapstructure:"postprocessall"`
+	Server         *ServerConfig   `yaml:"server,omitempty" json:"server,omitempty" toml:"server,omitempty" mapstructure:"server"`
+	Webhooks       []webhook.Hook  `yaml:"webhooks,omitempty" json:"webhooks,omitempty" toml:"webhooks,omitempty" mapstructure:"webhooks"`
+}
+
+// PlexConfig contains the Plex library paths
+type PlexConfig struct {
+	TVPath    string `yaml:"tvpath" json:"tvpath" toml:"tvpath" mapstructure:"tvpath"`
+	ErrorPath string `yaml:"errorpath" json:"errorpath" toml:"errorpath" mapstructure:"errorpath"`
+
+	// Specials is the folder season 0 goes in, under the show
+	// (Specials, unless it's set)
+	Specials string `yaml:"specials,omitempty" json:"specials,omitempty" toml:"specials,omitempty" mapstructure:"specials"`
+
+	// ShowYear is when the year is in show folder names, like
+	// 'Doctor Who (2005)': when the file name has one (the default),
+	// always (using an existing folder with a year, if there is one)
+	// or never
+	ShowYear string `yaml:"showyear,omitempty" json:"showyear,omitempty" toml:"showyear,omitempty" mapstructure:"showyear"`
+}
+
+// ScriptConfig points to a Starlark script with custom parse and naming rules
+type ScriptConfig struct {
+	// Path is the path to the script
+	Path string `yaml:"path" json:"path" toml:"path" mapstructure:"path"`
+
+	// Parse is when the script's parse function runs: before the built-in
+	// parser (the default), or instead of it
+	Parse string `yaml:"parse,omitempty" json:"parse,omitempty" toml:"parse,omitempty" mapstructure:"parse"`
+}
+
+// ParseRule is a custom file name format
+type ParseRule struct {
+	// Name identifies the rule in logs and reports
+	Name string `yaml:"name,omitempty" json:"name,omitempty" toml:"name,omitempty" mapstructure:"name"`
+
+	// Pattern is a regular expression with named groups (show, season,
+	// episode, year, month, day and title)
+	Pattern string `yaml:"pattern" json:"pattern" toml:"pattern" mapstructure:"pattern"`
+
+	// Order is when the rule is tried: before the built-in rules
+	// (the default), or after them
+	Order string `yaml:"order,omitempty" json:"order,omitempty" toml:"order,omitempty" mapstructure:"order"`
+
+	// Dirs and Tags limit the rule to files in (or below) the given
+	// directories, or passed with one of the given tags
+	Dirs []string `yaml:"dirs,omitempty" json:"dirs,omitempty" toml:"dirs,omitempty" mapstructure:"dirs"`
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty" toml:"tags,omitempty" mapstructure:"tags"`
+}
+
+// ServerConfig holds the settings for 'plexbot serve'
+type ServerConfig struct {
+	// Listen is the address to listen on, like 127.0.0.1:8090
+	Listen string `yaml:"listen,omitempty" json:"listen,omitempty" toml:"listen,omitempty" mapstructure:"listen"`
+
+	// Username and Password turn on basic authentication.  They're needed
+	// to listen on anything other than the loopback address
+	Username string `yaml:"username,omitempty" json:"username,omitempty" toml:"username,omitempty" mapstructure:"username"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty" toml:"password,omitempty" mapstructure:"password"`
+
+	// Roots are the download directories files can be imported from.
+	// Imports of files anywhere else are refused
+	Roots []string `yaml:"roots,omitempty" json:"roots,omitempty" toml:"roots,omitempty" mapstructure:"roots"`
+
+	// MoviePath is the library movies sent by Radarr are filed in
+	MoviePath string `yaml:"moviepath,omitempty" json:"moviepath,omitempty" toml:"moviepath,omitempty" mapstructure:"moviepath"`
+}
+
+// The times a custom parse rule can be tried
+const (
+	ParseRuleBefore = "before"
+	ParseRuleAfter  = "after"
+)
+
+// The times a script's parse function can run
+const (
+	ScriptParseBefore  = "before"
+	ScriptParseInstead = "instead"
+)
+
+// The times the year is in a show's folder name
+const (
+	ShowYearName   = "name"
+	ShowYearAlways = "always"
+	ShowYearNever  = "never"
+)
+
+// ShowYears are the settings for the year in show folder names
+var ShowYears = []string{ShowYearName, ShowYearAlways, ShowYearNever}
+
+// DefaultSpecials is the folder specials go in, if it isn't set
+const DefaultSpecials = "Specials"
+
+// Platforms is the list of platforms we have default settings for
+var Platforms = []string{"windows", "linux", "darwin"}
+
+// Default returns the default configuration for the given platform
+// (windows, linux or darwin).  Unknown platforms get the linux defaults
+func Default(platform string) Config {
+	switch platform {
+	case "windows":
+		return Config{
+			Version: CurrentVersion,
+			Plex: PlexConfig{
+				TVPath:    `d:\tv`,
+				ErrorPath: `d:\errors`,
+			},
+			PostProcess: Commands(`qbittorrentremove.exe -file "{oldfilepath}"`),
+		}
+	case "darwin":
+		return Config{
+			Version: CurrentVersion,
+			Plex: PlexConfig{
+				TVPath:    "/Users/Shared/Plex/TV",
+				ErrorPath: "/Users/Shared/Plex/Errors",
+			},
+			PostProcess: Commands(`qbittorrentremove -file "{oldfilepath}"`),
+		}
+	default:
+		return Config{
+			Version: CurrentVersion,
+			Plex: PlexConfig{
+				TVPath:    "/srv/media/tv",
+				ErrorPath: "/srv/media/errors",
+			},
+			PostProcess: Commands(`qbittorrentremove -file "{oldfilepath}"`),
+		}
+	}
+}
+
+// FromMap builds a Config from a map of settings (like the one returned from
+// viper.AllSettings), migrating older layouts to the current version first
+func FromMap(settings map[string]interface{}) (Config, error) {
+	cfg := Config{}
+
+	migrated, _, err := Migrate(settings)
+	if err != nil {
+		return cfg, err
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           &cfg,
+		WeaklyTypedInput: true,
+		TagName:          "mapstructure",
+		DecodeHook:       pluginDecodeHook,
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	migrated["shows"] = joinShowNames(migrated["shows"])
+	if err := decoder.Decode(migrated); err != nil {
+		return cfg, fmt.Errorf("problem reading the configuration: %v", err)
+	}
+
+	return cfg, nil
+}
+
+// Migrate converts settings from older config layouts to the current one.
+// It returns the migrated settings, along with a note for each change made
+func Migrate(settings map[string]interface{}) (map[string]interface{}, []string, error) {
+	var notes []string
+	migrated := make(map[string]interface{})
+	for k, v := range settings {
+		migrated[strings.ToLower(k)] = v
+	}
+
+	version, err := versionOf(migrated["version"])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if version > CurrentVersion {
+		return nil, nil, fmt.Errorf("config version %d is newer than this version of plexbot understands (%d)", version, CurrentVersion)
+	}
+
+	//	Version 1 (or no version at all) allowed the plugin commands to be
+	//	nested under a 'command' key, or to be a single string
+	if version < 2 {
+		for _, section := range pluginSections {
+			switch value := migrated[section].(type) {
+			case map[string]interface{}:
+				if command, ok := value["command"]; ok {
+					migrated[section] = command
+					notes = append(notes, fmt.Sprintf("'%s.command' is an older layout -- put the commands directly in a '%s' list", section, section))
+				}
+			case map[interface{}]interface{}:
+				if command, ok := value["command"]; ok {
+					migrated[section] = command
+					notes = append(notes, fmt.Sprintf("'%s.command' is an older layout -- put the commands directly in a '%s' list", section, section))
+				}
+			case string:
+				migrated[section] = []interface{}{value}
+				notes = append(notes, fmt.Sprintf("'%s' is a single command -- it should be a list", section))
+			}
+		}
+	}
+
+	migrated["version"] = CurrentVersion
+	return migrated, notes, nil
+}
+
+// CheckWritable makes sure plexbot can create files in the library paths
+// that exist.  It writes (and removes) a file in each of them, so it's only
+// done when asked, not before every run
+func (c Config) CheckWritable() []Problem {
+	var problems []Problem
+
+	if c.Plex.TVPath != "" {
+		if err := checkWritableDir(c.Plex.TVPath); err != nil {
+			problems = append(problems, Problem{Key: "plex.tvpath", Severity: SeverityError, Message: err.Error(), needles: []string{c.Plex.TVPath, "tvpath"}})
+		}
+	}
+
+	//	The errors path gets created when it's needed
+	if c.Plex.ErrorPath != "" {
+		if _, err := os.Stat(c.Plex.ErrorPath); err == nil {
+			if err := checkWritableDir(c.Plex.ErrorPath); err != nil {
+				problems = append(problems, Problem{Key: "plex.errorpath", Severity: SeverityError, Message: err.Error(), needles: []string{c.Plex.ErrorPath, "errorpath"}})
+			}
+		}
+	}
+
+	if c.Server != nil && c.Server.MoviePath != "" {
+		if err := checkWritableDir(c.Server.MoviePath); err != nil {
+			problems = append(problems, Problem{Key: "server.moviepath", Severity: SeverityError, Message: err.Error(), needles: []string{c.Server.MoviePath, "moviepath"}})
+		}
+	}
+
+	return problems
+}
+
+// Validate checks the settings in the config for problems that would
+// stop plexbot from working correctly.  It doesn't write anything: see
+// CheckWritable
+func (c Config) Validate() []Problem {
+	var problems []Problem
+
+	if c.Plex.TVPath == "" {
+		problems = append(problems, Problem{Key: "plex.tvpath", Severity: SeverityError, Message: "The Plex TV library path isn't set", needles: []string{"plex"}})
+	} else if err := checkDir(c.Plex.TVPath); err != nil {
+		problems = append(problems, Problem{Key: "plex.tvpath", Severity: SeverityError, Message: err.Error(), needles: []string{c.Plex.TVPath, "tvpath"}})
+	}
+
+	if c.Plex.ErrorPath == "" {
+		problems = append(problems, Problem{Key: "plex.errorpath", Severity: SeverityError, Message: "The errors path isn't set", needles: []string{"plex"}})
+	} else if _, err := os.Stat(c.Plex.ErrorPath); os.IsNotExist(err) {
+		//	The errors path gets created when it's needed
+		problems = append(problems, Problem{Key: "plex.errorpath", Severity: SeverityWarning, Message: fmt.Sprintf("The directory doesn't exist yet and will be created: %v", c.Plex.ErrorPath), needles: []string{c.Plex.ErrorPath, "errorpath"}})
+	} else if err := checkDir(c.Plex.ErrorPath); err != nil {
+		problems = append(problems, Problem{Key: "plex.errorpath", Severity: SeverityError, Message: err.Error(), needles: []string{c.Plex.ErrorPath, "errorpath"}})
+	}
+
+	plugins := map[string][]Plugin{
+		"preprocess":     c.PreProcess,
+		"premove":        c.PreMove,
+		"postprocess":    c.PostProcess,
+		"postprocessall": c.PostProcessAll,
+	}
+	for _, section := range pluginSections {
+		for index, p := range plugins[section] {
+			problems = append(problems, checkPlugin(section, fmt.Sprintf("%s[%d]", section, index), p)...)
+		}
+	}
+
+	if c.Script != nil {
+		problems = append(problems, checkScript(*c.Script)...)
+	}
+
+	if c.DateOrder != "" && !containsString(parser.DateOrders, c.DateOrder) {
+		problems = append(problems, Problem{Key: "dateorder", Severity: SeverityError, Message: fmt.Sprintf("Unknown date order %q (use %s)", c.DateOrder, strings.Join(parser.DateOrders, " or ")), needles: []string{"dateorder"}})
+	}
+
+	if strings.ContainsAny(c.Plex.Specials, `/\`) {
+		problems = append(problems, Problem{Key: "plex.specials", Severity: SeverityError, Message: "The specials folder should be a folder name, not a path", needles: []string{"specials"}})
+	}
+
+	if c.Plex.ShowYear != "" && !containsString(ShowYears, c.Plex.ShowYear) {
+		problems = append(problems, Problem{Key: "plex.showyear", Severity: SeverityError, Message: fmt.Sprintf("Unknown show year setting %q (use %s)", c.Plex.ShowYear, strings.Join(ShowYears, ", ")), needles: []string{"showyear"}})
+	}
+
+	if c.MinConfidence < 0 || c.MinConfidence > 1 {
+		problems = append(problems, Problem{Key: "minconfidence", Severity: SeverityError, Message: "The minimum confidence should be between 0 and 1", needles: []string{"minconfidence"}})
+	}
+
+	for index, rule := range c.Parsers {
+		problems = append(problems, checkParseRule(fmt.Sprintf("parsers[%d]", index), rule)...)
+	}
+
+	for _, name := range sortedShows(c.Shows) {
+		problems = append(problems, checkShow("shows."+name, c.Shows[name])...)
+	}
+
+	if c.Server != nil && (c.Server.Username == "") != (c.Server.Password == "") {
+		problems = append(problems, Problem{Key: "server", Severity: SeverityError, Message: "Set both a username and a password, or neither", needles: []string{"server"}})
+	}
+
+	if c.Server != nil {
+		for _, root := range c.Server.Roots {
+			if !filepath.IsAbs(root) {
+				problems = append(problems, Problem{Key: "server.roots", Severity: SeverityError, Message: fmt.Sprintf("The download directory %q should be a full path", root), needles: []string{root}})
+			} else if info, err := os.Stat(root); err != nil || !info.IsDir() {
+				problems = append(problems, Problem{Key: "server.roots", Severity: SeverityWarning, Message: fmt.Sprintf("The download directory %q doesn't exist on this host", root), needles: []string{root}})
+			}
+		}
+
+		movies := c.Server.MoviePath
+		if movies != "" && !filepath.IsAbs(movies) {
+			problems = append(problems, Problem{Key: "server.moviepath", Severity: SeverityError, Message: fmt.Sprintf("The movie library %q should be a full path", movies), needles: []string{movies, "moviepath"}})
+		} else if movies != "" {
+			if err := checkDir(movies); err != nil {
+				problems = append(problems, Problem{Key: "server.moviepath", Severity: SeverityError, Message: err.Error(), needles: []string{movies, "moviepath"}})
+			}
+		}
+	}
+
+	for index, hook := range c.Webhooks {
+		problems = append(problems, checkWebhook(fmt.Sprintf("webhooks[%d]", index), hook)...)
+	}
+
+	return problems
+}
+
+// versionOf reads the config version, which might have been parsed as
+// any number type (or not be there at all)
+func versionOf(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case nil:
+		return 1, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	case string:
+		var version int
+		if _, err := fmt.Sscanf(v, "%d", &version); err != nil {
+			return 0, fmt.Errorf("the config version %q isn't a number", v)
+		}
+		return version, nil
+	}
+	return 0, fmt.Errorf("the config version %v isn't a number", value)
+}
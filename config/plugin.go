@@ -0,0 +1,76 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// Plugin is a single entry in the preprocess, postprocess or postprocessall
+// sections.  It either runs a command or a built-in action.  If it only has a
+// command, it can be written as just the command
+type Plugin struct {
+	// Command is the command to run, with tokens
+	Command string `yaml:"command,omitempty" json:"command,omitempty" toml:"command,omitempty" mapstructure:"command"`
+
+	// Builtin holds the settings for a built-in action (instead of a command)
+	plugin.Builtin `yaml:",inline" mapstructure:",squash"`
+
+	// Stdin is what to write to the plugin's stdin.  Set it to 'json' to
+	// get a JSON document describing the file and the run
+	Stdin string `yaml:"stdin,omitempty" json:"stdin,omitempty" toml:"stdin,omitempty" mapstructure:"stdin"`
+
+	// When holds the conditions for running the plugin.  If it's not set,
+	// the plugin always runs
+	When *When `yaml:"when,omitempty" json:"when,omitempty" toml:"when,omitempty" mapstructure:"when"`
+}
+
+// Use an alias so marshalling the full form doesn't call our marshallers again
+type pluginFields Plugin
+
+// MarshalJSON writes the plugin as just the command if that's all it has
+func (p Plugin) MarshalJSON() ([]byte, error) {
+	if p.isCommandOnly() {
+		return json.Marshal(p.Command)
+	}
+	return json.Marshal(pluginFields(p))
+}
+
+// MarshalYAML writes the plugin as just the command if that's all it has
+func (p Plugin) MarshalYAML() (interface{}, error) {
+	if p.isCommandOnly() {
+		return p.Command, nil
+	}
+	return pluginFields(p), nil
+}
+
+// isCommandOnly returns true if the plugin only has a command
+func (p Plugin) isCommandOnly() bool {
+	return p.Action == "" && p.Stdin == "" && p.When == nil
+}
+
+// String describes the plugin for logs and reports
+func (p Plugin) String() string {
+	if p.Action != "" {
+		return p.Builtin.String()
+	}
+	return p.Command
+}
+
+// Commands returns plugins for each of the given commands
+func Commands(commands ...string) []Plugin {
+	plugins := make([]Plugin, 0, len(commands))
+	for _, command := range commands {
+		plugins = append(plugins, Plugin{Command: command})
+	}
+	return plugins
+}
+
+// pluginDecodeHook lets plugins be written as just the command string
+func pluginDecodeHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() == reflect.String && to == reflect.TypeOf(Plugin{}) {
+		return Plugin{Command: data.(string)}, nil
+	}
+	return data, nil
+}
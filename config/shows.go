@@ -0,0 +1,202 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Show holds the settings for a single show.  They're keyed by the show's
+// name in the shows section, like 'Doctor Who (2005)'
+type Show struct {
+	// SeasonOffset and EpisodeOffset are added to the parsed season and
+	// episode, for releases numbered differently than the metadata agent
+	SeasonOffset  int `yaml:"seasonoffset,omitempty" json:"seasonoffset,omitempty" toml:"seasonoffset,omitempty" mapstructure:"seasonoffset"`
+	EpisodeOffset int `yaml:"episodeoffset,omitempty" json:"episodeoffset,omitempty" toml:"episodeoffset,omitempty" mapstructure:"episodeoffset"`
+
+	// Absolute is the absolute episode number each season starts at,
+	// starting with season 1.  Episodes parsed as season 1 (like
+	// 'Show - 27') are filed in the season they fall in
+	Absolute []int `yaml:"absolute,omitempty" json:"absolute,omitempty" toml:"absolute,omitempty" mapstructure:"absolute"`
+
+	// Ignore leaves the show's files where they are
+	Ignore bool `yaml:"ignore,omitempty" json:"ignore,omitempty" toml:"ignore,omitempty" mapstructure:"ignore"`
+
+	// Library is the path of the library the show goes in, instead of
+	// the Plex TV path
+	Library string `yaml:"library,omitempty" json:"library,omitempty" toml:"library,omitempty" mapstructure:"library"`
+
+	// Naming is the destination for the show's episodes, relative to
+	// its library.  It can use the NamingTokens
+	Naming string `yaml:"naming,omitempty" json:"naming,omitempty" toml:"naming,omitempty" mapstructure:"naming"`
+}
+
+var (
+	// NamingTokens are the tokens that can be used in a show's naming template
+	NamingTokens = []string{
+		"{show}",
+		"{showname}",
+		"{showyear}",
+		"{season}",
+		"{season00}",
+		"{episode}",
+		"{episode00}",
+		"{airdate}",
+		"{title}",
+		"{quality}",
+		"{filename}",
+		"{ext}",
+	}
+
+	//	The settings a show can have
+	showKeys = []string{"seasonoffset", "episodeoffset", "absolute", "ignore", "library", "naming"}
+)
+
+// FindShow returns the settings for the first of the names that's in the
+// shows section, along with the name it's listed under.  Names are compared
+// by their letters and numbers, so 'Mr Robot' finds 'Mr. Robot'
+func (c Config) FindShow(names ...string) (string, Show, bool) {
+	for _, name := range names {
+		for key, show := range c.Shows {
+			if showKey(key) == showKey(name) {
+				return key, show, true
+			}
+		}
+	}
+	return "", Show{}, false
+}
+
+// showKey returns the letters and numbers in a show name, in lowercase
+func showKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// sortedShows returns the names of the shows, sorted
+func sortedShows(shows map[string]Show) []string {
+	var names []string
+	for name := range shows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// joinShowNames puts back together the show names that viper splits at
+// the dots (it reads 'mr. robot' as 'mr' with a ' robot' inside it)
+func joinShowNames(value interface{}) interface{} {
+	shows, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+
+	joined := make(map[string]interface{})
+	var walk func(name string, settings map[string]interface{})
+	walk = func(name string, settings map[string]interface{}) {
+		for key := range settings {
+			if containsString(showKeys, key) {
+				joined[name] = settings
+				return
+			}
+		}
+
+		for key, child := range settings {
+			if nested, ok := child.(map[string]interface{}); ok {
+				walk(name+"."+key, nested)
+			}
+		}
+	}
+
+	for name, settings := range shows {
+		if nested, ok := settings.(map[string]interface{}); ok {
+			walk(name, nested)
+		} else {
+			joined[name] = settings
+		}
+	}
+	return joined
+}
+
+// checkShows makes sure the shows section is a set of shows, each with
+// a set of settings
+func (v *validator) checkShows(settings map[string]interface{}) {
+	value, ok := settings["shows"]
+	if !ok || value == nil {
+		return
+	}
+
+	shows, ok := value.(map[string]interface{})
+	if !ok {
+		v.add(v.keyLine("shows"), "shows", SeverityError, "Should be a set of shows, keyed by their names")
+		return
+	}
+
+	showsLine := v.keyLine("shows")
+	for _, name := range sortedKeys(shows) {
+		key := "shows." + name
+		show, ok := shows[name].(map[string]interface{})
+		if !ok {
+			v.add(v.lineAfter(showsLine, name), key, SeverityError, fmt.Sprintf("Shows should have a set of settings, not %T", shows[name]))
+			continue
+		}
+
+		for _, child := range sortedKeys(show) {
+			if !containsString(showKeys, child) {
+				v.add(v.childLine(v.lineAfter(showsLine, name), child), key+"."+child, SeverityWarning, "Unknown key")
+			}
+		}
+	}
+}
+
+// checkShow checks the settings for a single show
+func checkShow(key string, show Show) []Problem {
+	var problems []Problem
+
+	for index, start := range show.Absolute {
+		if start < 1 || (index > 0 && start <= show.Absolute[index-1]) {
+			problems = append(problems, Problem{Key: key + ".absolute", Severity: SeverityError, Message: "The absolute episode numbers should start at 1 or more, and go up with each season", needles: []string{"absolute"}})
+			break
+		}
+	}
+
+	if show.Library != "" {
+		if !filepath.IsAbs(show.Library) {
+			problems = append(problems, Problem{Key: key + ".library", Severity: SeverityError, Message: "The library should be a full path", needles: []string{show.Library}})
+		} else if info, err := os.Stat(show.Library); err != nil || !info.IsDir() {
+			problems = append(problems, Problem{Key: key + ".library", Severity: SeverityWarning, Message: fmt.Sprintf("The library %q doesn't exist on this host", show.Library), needles: []string{show.Library}})
+		}
+	}
+
+	if show.Naming != "" && !relativeNaming(show.Naming) {
+		problems = append(problems, Problem{Key: key + ".naming", Severity: SeverityError, Message: "The naming should be a path inside the library, without a leading / or any '..'", needles: []string{show.Naming}})
+	}
+
+	for _, token := range rxToken.FindAllString(show.Naming, -1) {
+		if !containsString(NamingTokens, token) {
+			problems = append(problems, Problem{Key: key + ".naming", Severity: SeverityError, Message: fmt.Sprintf("Unknown token %v (use %s)", token, strings.Join(NamingTokens, ", ")), needles: []string{token, show.Naming}})
+		}
+	}
+
+	return problems
+}
+
+// relativeNaming returns true if the naming template stays inside the
+// library: it isn't a full path, and none of its folders are '..'
+func relativeNaming(naming string) bool {
+	if filepath.IsAbs(naming) || filepath.VolumeName(naming) != "" || strings.HasPrefix(naming, "/") || strings.HasPrefix(naming, `\`) {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(naming, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if strings.TrimSpace(part) == ".." {
+			return false
+		}
+	}
+	return true
+}
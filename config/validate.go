@@ -0,0 +1,708 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danesparza/plexbot/parser"
+	"github.com/danesparza/plexbot/plugin"
+	"github.com/danesparza/plexbot/script"
+	"github.com/danesparza/plexbot/webhook"
+	toml "github.com/pelletier/go-toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Severity indicates how serious a validation problem is
+type Severity string
+
+const (
+	// SeverityError is a problem that will stop plexbot from working correctly
+	SeverityError Severity = "error"
+
+	// SeverityWarning is a problem that might be intentional
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is a single issue found while validating a config file
+type Problem struct {
+	File     string
+	Line     int
+	Key      string
+	Severity Severity
+	Message  string
+
+	//	Strings to look for when finding the line in the config file
+	needles []string
+}
+
+// String formats the problem as 'file:line: severity: key: message'
+func (p Problem) String() string {
+	var parts []string
+	if p.File != "" && p.Line > 0 {
+		parts = append(parts, fmt.Sprintf("%s:%d", p.File, p.Line))
+	} else if p.File != "" {
+		parts = append(parts, p.File)
+	}
+
+	parts = append(parts, string(p.Severity))
+	if p.Key != "" {
+		parts = append(parts, p.Key)
+	}
+	parts = append(parts, p.Message)
+
+	return strings.Join(parts, ": ")
+}
+
+// HasErrors returns true if any of the problems are errors
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	//	The keys we know about, and the sections that can contain plugin commands
+	knownKeys = map[string][]string{
+		"version":        nil,
+		"plex":           {"tvpath", "errorpath", "specials", "showyear"},
+		"script":         {"path", "parse"},
+		"dateorder":      nil,
+		"parsers":        nil,
+		"minconfidence":  nil,
+		"shows":          nil,
+		"server":         {"listen", "username", "password", "roots", "moviepath"},
+		"preprocess":     nil,
+		"premove":        nil,
+		"postprocess":    nil,
+		"postprocessall": nil,
+		"webhooks":       nil,
+	}
+	pluginSections = []string{"preprocess", "premove", "postprocess", "postprocessall"}
+	pluginKeys     = []string{"command", "stdin", "when", "action", "path", "target", "content", "mode", "url", "method", "headers", "body"}
+	whenKeys       = []string{"tags", "parsetype", "show", "library", "extension", "minsize", "maxsize", "outcome", "previous"}
+	stdinModes     = []string{"", "json"}
+	parseRuleKeys  = []string{"name", "pattern", "order", "dirs", "tags"}
+	webhookKeys    = []string{"url", "method", "headers", "body", "events", "retries", "backoff", "secret", "signatureheader"}
+
+	//	Finds tokens like {showname} in plugin commands
+	rxToken = regexp.MustCompile(`\{[A-Za-z0-9_]+\}`)
+
+	//	Finds line numbers in parser error messages
+	rxErrorLine = regexp.MustCompile(`line (\d+)|^\((\d+), \d+\)`)
+)
+
+// validator collects the problems found in a single config file
+type validator struct {
+	file     string
+	raw      []byte
+	problems []Problem
+}
+
+// Validate checks the config file at the given path.  It makes sure the file
+// exists and parses, that the paths it points to exist and are writable,
+// that there are no unknown keys, and that the plugin commands are well formed
+// and only use known tokens
+func Validate(path string) []Problem {
+	return validate(path, true)
+}
+
+// Check is Validate without writing to the library paths, for the checks
+// made before each run
+func Check(path string) []Problem {
+	return validate(path, false)
+}
+
+// validate checks the config file at the given path, and if asked, makes
+// sure the library paths are writable
+func validate(path string, writable bool) []Problem {
+	v := &validator{file: path}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		v.add(0, "", SeverityError, fmt.Sprintf("Can't read the config file: %v", err))
+		return v.problems
+	}
+	v.raw = raw
+
+	settings, err := parse(path, raw)
+	if err != nil {
+		v.add(errorLine(err, raw), "", SeverityError, fmt.Sprintf("Can't parse the config file: %v", err))
+		return v.problems
+	}
+
+	v.checkKeys(settings)
+	v.checkPlugins(settings)
+	v.checkParsers(settings)
+	v.checkShows(settings)
+	v.checkWebhooks(settings)
+	if HasErrors(v.problems) {
+		return v.problems
+	}
+
+	_, notes, err := Migrate(settings)
+	if err != nil {
+		v.add(v.lineOf("version"), "version", SeverityError, err.Error())
+		return v.problems
+	}
+	for _, note := range notes {
+		v.add(0, "", SeverityWarning, note)
+	}
+
+	cfg, err := FromMap(settings)
+	if err != nil {
+		v.add(0, "", SeverityError, err.Error())
+		return v.problems
+	}
+
+	problems := cfg.Validate()
+	if writable && !HasErrors(problems) {
+		problems = append(problems, cfg.CheckWritable()...)
+	}
+
+	for _, problem := range problems {
+		problem.File = v.file
+		problem.Line = v.lineOf(problem.needles...)
+		v.problems = append(v.problems, problem)
+	}
+
+	return v.problems
+}
+
+// parse reads the raw config file into a map, based on the file extension
+func parse(path string, raw []byte) (map[string]interface{}, error) {
+	var settings interface{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(raw, &settings); err != nil {
+			return nil, err
+		}
+	case ".json":
+		if err := json.Unmarshal(raw, &settings); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		tree, err := toml.LoadBytes(raw)
+		if err != nil {
+			return nil, err
+		}
+		settings = tree.ToMap()
+	default:
+		return nil, fmt.Errorf("unsupported config file type %q (use .yaml, .json or .toml)", filepath.Ext(path))
+	}
+
+	if settings == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	normalized, ok := normalize(settings).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the config file should contain a set of keys, not a %T", settings)
+	}
+	return normalized, nil
+}
+
+// normalize converts the maps produced by the various parsers into
+// maps with lowercase string keys (which is how viper sees them)
+func normalize(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{})
+		for k, v := range typed {
+			result[strings.ToLower(fmt.Sprintf("%v", k))] = normalize(v)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{})
+		for k, v := range typed {
+			result[strings.ToLower(k)] = normalize(v)
+		}
+		return result
+	case []interface{}:
+		for i, v := range typed {
+			typed[i] = normalize(v)
+		}
+		return typed
+	}
+	return value
+}
+
+// checkKeys flags any keys we don't know about
+func (v *validator) checkKeys(settings map[string]interface{}) {
+	for _, key := range sortedKeys(settings) {
+		children, known := knownKeys[key]
+		if !known {
+			v.add(v.keyLine(key), key, SeverityWarning, "Unknown key")
+			continue
+		}
+
+		section, isSection := settings[key].(map[string]interface{})
+		if children == nil || !isSection {
+			continue
+		}
+
+		for _, child := range sortedKeys(section) {
+			if !containsString(children, child) {
+				v.add(v.childLine(v.keyLine(key), child), key+"."+child, SeverityWarning, "Unknown key")
+			}
+		}
+	}
+}
+
+// checkPlugins makes sure each plugin section is a list of commands.
+// The commands themselves are checked by Config.Validate
+func (v *validator) checkPlugins(settings map[string]interface{}) {
+	for _, section := range pluginSections {
+		value, ok := settings[section]
+		if !ok || value == nil {
+			continue
+		}
+
+		sectionLine := v.keyLine(section)
+		items, ok := value.([]interface{})
+		if !ok {
+			//	Older layouts get migrated
+			if _, isMap := value.(map[string]interface{}); isMap {
+				continue
+			}
+			if _, isString := value.(string); isString {
+				continue
+			}
+			v.add(sectionLine, section, SeverityError, "Should be a list of plugin commands")
+			continue
+		}
+
+		for index, item := range items {
+			key := fmt.Sprintf("%s[%d]", section, index)
+			switch typed := item.(type) {
+			case string:
+			case map[string]interface{}:
+				for _, child := range sortedKeys(typed) {
+					if !containsString(pluginKeys, child) {
+						v.add(v.childLine(sectionLine, child), key+"."+child, SeverityWarning, "Unknown key")
+					}
+				}
+				if when, ok := typed["when"].(map[string]interface{}); ok {
+					for _, child := range sortedKeys(when) {
+						if !containsString(whenKeys, child) {
+							v.add(v.childLine(sectionLine, child), key+".when."+child, SeverityWarning, "Unknown condition")
+						}
+					}
+				}
+				_, hasCommand := typed["command"].(string)
+				_, hasAction := typed["action"].(string)
+				if hasCommand == hasAction {
+					v.add(sectionLine, key, SeverityError, "Plugins need either a command or an action")
+				}
+			default:
+				v.add(sectionLine, key, SeverityError, fmt.Sprintf("Plugins should be a command or a set of plugin settings, not %T", item))
+			}
+		}
+	}
+}
+
+// checkScript makes sure the script compiles
+func checkScript(s ScriptConfig) []Problem {
+	var problems []Problem
+
+	if s.Parse != "" && s.Parse != ScriptParseBefore && s.Parse != ScriptParseInstead {
+		problems = append(problems, Problem{Key: "script.parse", Severity: SeverityError, Message: fmt.Sprintf("Unknown value %q (use %s or %s)", s.Parse, ScriptParseBefore, ScriptParseInstead), needles: []string{s.Parse}})
+	}
+
+	if s.Path == "" {
+		return append(problems, Problem{Key: "script.path", Severity: SeverityError, Message: "The script path isn't set", needles: []string{"script"}})
+	}
+
+	if _, err := script.Load(s.Path); err != nil {
+		problems = append(problems, Problem{Key: "script.path", Severity: SeverityError, Message: fmt.Sprintf("The script won't load: %v", err), needles: []string{s.Path}})
+	}
+
+	return problems
+}
+
+// checkParsers makes sure the parsers section is a list of parse rules
+func (v *validator) checkParsers(settings map[string]interface{}) {
+	value, ok := settings["parsers"]
+	if !ok || value == nil {
+		return
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		v.add(v.keyLine("parsers"), "parsers", SeverityError, "Should be a list of parse rules")
+		return
+	}
+
+	for index, item := range items {
+		key := fmt.Sprintf("parsers[%d]", index)
+		rule, ok := item.(map[string]interface{})
+		if !ok {
+			v.add(v.keyLine("parsers"), key, SeverityError, fmt.Sprintf("Parse rules should be a set of rule settings, not %T", item))
+			continue
+		}
+
+		for _, child := range sortedKeys(rule) {
+			if !containsString(parseRuleKeys, child) {
+				v.add(v.childLine(v.keyLine("parsers"), child), key+"."+child, SeverityWarning, "Unknown key")
+			}
+		}
+	}
+}
+
+// checkParseRule checks the settings for a single parse rule
+func checkParseRule(key string, rule ParseRule) []Problem {
+	var problems []Problem
+
+	if rule.Order != "" && rule.Order != ParseRuleBefore && rule.Order != ParseRuleAfter {
+		problems = append(problems, Problem{Key: key + ".order", Severity: SeverityError, Message: fmt.Sprintf("Unknown value %q (use %s or %s)", rule.Order, ParseRuleBefore, ParseRuleAfter), needles: []string{rule.Order}})
+	}
+
+	if rule.Pattern == "" {
+		return append(problems, Problem{Key: key + ".pattern", Severity: SeverityError, Message: "The parse rule needs a pattern", needles: []string{"parsers"}})
+	}
+
+	if _, err := parser.NewRule(rule.Name, rule.Pattern); err != nil {
+		problems = append(problems, Problem{Key: key + ".pattern", Severity: SeverityError, Message: fmt.Sprintf("The pattern won't compile: %v", err), needles: []string{rule.Pattern, "pattern"}})
+	}
+
+	for _, dir := range rule.Dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			problems = append(problems, Problem{Key: key + ".dirs", Severity: SeverityWarning, Message: fmt.Sprintf("The directory %q doesn't exist on this host", dir), needles: []string{dir}})
+		}
+	}
+
+	return problems
+}
+
+// checkWebhooks makes sure the webhooks section is a list of webhook settings
+func (v *validator) checkWebhooks(settings map[string]interface{}) {
+	value, ok := settings["webhooks"]
+	if !ok || value == nil {
+		return
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		v.add(v.keyLine("webhooks"), "webhooks", SeverityError, "Should be a list of webhooks")
+		return
+	}
+
+	for index, item := range items {
+		key := fmt.Sprintf("webhooks[%d]", index)
+		hook, ok := item.(map[string]interface{})
+		if !ok {
+			v.add(v.keyLine("webhooks"), key, SeverityError, fmt.Sprintf("Webhooks should be a set of webhook settings, not %T", item))
+			continue
+		}
+
+		for _, child := range sortedKeys(hook) {
+			if !containsString(webhookKeys, child) {
+				v.add(v.childLine(v.keyLine("webhooks"), child), key+"."+child, SeverityWarning, "Unknown key")
+			}
+		}
+	}
+}
+
+// checkWebhook checks the settings for a single webhook
+func checkWebhook(key string, hook webhook.Hook) []Problem {
+	var problems []Problem
+
+	if hook.URL == "" {
+		problems = append(problems, Problem{Key: key + ".url", Severity: SeverityError, Message: "The webhook needs a url", needles: []string{"webhooks"}})
+	} else if u, err := url.Parse(hook.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, Problem{Key: key + ".url", Severity: SeverityError, Message: fmt.Sprintf("%q isn't a valid url", hook.URL), needles: []string{hook.URL}})
+	}
+
+	for _, event := range hook.Events {
+		if !containsString(webhook.Events, strings.ToLower(event)) {
+			problems = append(problems, Problem{Key: key + ".events", Severity: SeverityError, Message: fmt.Sprintf("Unknown event %q (use %s)", event, strings.Join(webhook.Events, ", ")), needles: []string{event}})
+		}
+	}
+
+	if _, err := hook.Parse(); err != nil {
+		problems = append(problems, Problem{Key: key + ".body", Severity: SeverityError, Message: fmt.Sprintf("The body template doesn't parse: %v", err), needles: []string{"body"}})
+	}
+
+	if backoff, err := hook.BackoffDuration(); err != nil || backoff < 0 {
+		problems = append(problems, Problem{Key: key + ".backoff", Severity: SeverityError, Message: fmt.Sprintf("%q isn't a valid duration (like 2s or 500ms)", hook.Backoff), needles: []string{hook.Backoff}})
+	}
+
+	if hook.Retries < 0 {
+		problems = append(problems, Problem{Key: key + ".retries", Severity: SeverityError, Message: "The number of retries can't be negative", needles: []string{"retries"}})
+	}
+
+	if hook.SignatureHeader != "" && hook.Secret == "" {
+		problems = append(problems, Problem{Key: key + ".signatureheader", Severity: SeverityWarning, Message: "The signature header is only sent when there's a secret", needles: []string{hook.SignatureHeader}})
+	}
+
+	return problems
+}
+
+// checkPlugin checks a single plugin in the given section
+func checkPlugin(section, key string, p Plugin) []Problem {
+	var problems []Problem
+	command := p.Command
+
+	if p.When != nil {
+		problems = append(problems, p.When.validate(section, key)...)
+	}
+
+	//	Premove hooks have to reply with JSON, which the built-in actions can't do
+	if p.Action != "" && section == "premove" {
+		return append(problems, Problem{Key: key + ".action", Severity: SeverityError, Message: "Premove hooks have to be a command", needles: []string{p.Action}})
+	}
+
+	if p.Action != "" {
+		return append(problems, checkBuiltin(section, key, p)...)
+	}
+
+	if p.Stdin != "" && section == "premove" {
+		problems = append(problems, Problem{Key: key + ".stdin", Severity: SeverityWarning, Message: "Premove hooks always get the plan on stdin as JSON", needles: []string{"stdin"}})
+	}
+
+	if !containsString(stdinModes, p.Stdin) {
+		problems = append(problems, Problem{Key: key + ".stdin", Severity: SeverityError, Message: fmt.Sprintf("Unknown stdin mode %q (use 'json')", p.Stdin), needles: []string{p.Stdin}})
+	}
+
+	if strings.TrimSpace(command) == "" {
+		return append(problems, Problem{Key: key, Severity: SeverityError, Message: "The plugin command is empty"})
+	}
+
+	if strings.Count(command, `"`)%2 != 0 {
+		problems = append(problems, Problem{Key: key, Severity: SeverityError, Message: "The plugin command has an unbalanced quote", needles: []string{command}})
+	}
+
+	problems = append(problems, checkTokens(section, key, command)...)
+
+	head := strings.TrimSpace(strings.Split(command, " -")[0])
+	if _, err := exec.LookPath(head); err != nil {
+		problems = append(problems, Problem{Key: key, Severity: SeverityWarning, Message: fmt.Sprintf("Can't find the plugin executable %q on this host", head), needles: []string{command}})
+	}
+
+	return problems
+}
+
+// checkBuiltin checks the settings for a built-in action
+func checkBuiltin(section, key string, p Plugin) []Problem {
+	var problems []Problem
+	b := p.Builtin
+
+	if !containsString(plugin.Actions, b.Action) {
+		return append(problems, Problem{Key: key + ".action", Severity: SeverityError, Message: fmt.Sprintf("Unknown action %q (use %s)", b.Action, strings.Join(plugin.Actions, ", ")), needles: []string{b.Action}})
+	}
+
+	if p.Command != "" {
+		problems = append(problems, Problem{Key: key, Severity: SeverityError, Message: "Plugins can have a command or an action, but not both", needles: []string{p.Command}})
+	}
+
+	if p.Stdin != "" {
+		problems = append(problems, Problem{Key: key + ".stdin", Severity: SeverityWarning, Message: "Built-in actions don't read stdin", needles: []string{"stdin"}})
+	}
+
+	//	The settings each action needs
+	required := map[string][]string{
+		"http":       {"url"},
+		"symlink":    {"path"},
+		"write-file": {"path"},
+		"chmod":      {"mode"},
+	}
+	settings := map[string]string{
+		"path":    b.Path,
+		"target":  b.Target,
+		"content": b.Content,
+		"mode":    b.Mode,
+		"url":     b.URL,
+		"body":    b.Body,
+	}
+	for _, name := range required[b.Action] {
+		if settings[name] == "" {
+			problems = append(problems, Problem{Key: key + "." + name, Severity: SeverityError, Message: fmt.Sprintf("The %v action needs a %v", b.Action, name), needles: []string{b.Action}})
+		}
+	}
+
+	if b.Action == "chmod" && b.Mode != "" {
+		if _, err := plugin.ParseMode(b.Mode); err != nil {
+			problems = append(problems, Problem{Key: key + ".mode", Severity: SeverityError, Message: err.Error(), needles: []string{b.Mode}})
+		}
+	}
+
+	//	Deleting the source only makes sense once the file has been moved
+	if b.Action == "delete-source" && section != "postprocess" {
+		problems = append(problems, Problem{Key: key + ".action", Severity: SeverityError, Message: "delete-source can only be used in the postprocess section", needles: []string{b.Action}})
+	}
+
+	for _, name := range sortedStringKeys(settings) {
+		problems = append(problems, checkTokens(section, key+"."+name, settings[name])...)
+	}
+	for _, name := range sortedStringKeys(b.Headers) {
+		problems = append(problems, checkTokens(section, key+".headers."+name, b.Headers[name])...)
+	}
+
+	return problems
+}
+
+// checkTokens makes sure the value only uses tokens that
+// are available in the given section
+func checkTokens(section, key, value string) []Problem {
+	var problems []Problem
+
+	for _, token := range rxToken.FindAllString(value, -1) {
+		if !containsString(plugin.TokensFor(section), token) {
+			message := fmt.Sprintf("Unknown token %v", token)
+			if containsString(plugin.FileTokens, token) || containsString(plugin.RunTokens, token) {
+				message = fmt.Sprintf("The token %v can't be used in the %v section", token, section)
+			}
+			problems = append(problems, Problem{Key: key, Severity: SeverityError, Message: message, needles: []string{token, value}})
+		}
+	}
+
+	return problems
+}
+
+// add records a problem
+func (v *validator) add(line int, key string, severity Severity, message string) {
+	v.problems = append(v.problems, Problem{
+		File:     v.file,
+		Line:     line,
+		Key:      key,
+		Severity: severity,
+		Message:  message,
+	})
+}
+
+// lineOf returns the first non-comment line in the raw config containing
+// any of the given strings, or 0 if none of them can be found
+func (v *validator) lineOf(needles ...string) int {
+	return v.lineAfter(0, needles...)
+}
+
+// keyLine returns the line a top level key is on, in any of the formats
+func (v *validator) keyLine(key string) int {
+	return v.lineOf(key+":", `"`+key+`"`, "[["+key+"]]", "["+key+"]", key+" =", key)
+}
+
+// childLine returns the line a key inside a section is on.  It's looked for
+// after the section's line first, so it isn't confused with a key of the
+// same name elsewhere
+func (v *validator) childLine(section int, child string) int {
+	needles := []string{child + ":", `"` + child + `"`, child + " =", child}
+	if line := v.lineAfter(section, needles...); line > 0 {
+		return line
+	}
+	return v.lineOf(needles...)
+}
+
+// lineAfter is lineOf, only looking at the lines after the given one
+func (v *validator) lineAfter(after int, needles ...string) int {
+	lines := strings.Split(string(v.raw), "\n")
+
+	for _, needle := range needles {
+		if needle == "" {
+			continue
+		}
+
+		//	Look for the string as it is, and as it would be escaped in a quoted string
+		escaped := strings.Trim(strconv.Quote(needle), `"`)
+		for index, line := range lines {
+			if index < after || strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+
+			if strings.Contains(line, needle) || strings.Contains(line, escaped) {
+				return index + 1
+			}
+		}
+	}
+	return 0
+}
+
+// errorLine finds the line number of a parse error
+func errorLine(err error, raw []byte) int {
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		return bytes.Count(raw[:syntaxErr.Offset], []byte("\n")) + 1
+	}
+
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		return bytes.Count(raw[:typeErr.Offset], []byte("\n")) + 1
+	}
+
+	if matches := rxErrorLine.FindStringSubmatch(err.Error()); matches != nil {
+		line, _ := strconv.Atoi(matches[1] + matches[2])
+		return line
+	}
+
+	return 0
+}
+
+// checkDir makes sure the given directory exists
+func checkDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("The directory doesn't exist: %v", dir)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("Not a directory: %v", dir)
+	}
+
+	return nil
+}
+
+// checkWritableDir makes sure the given directory exists and
+// that we can create files in it
+func checkWritableDir(dir string) error {
+	if err := checkDir(dir); err != nil {
+		return err
+	}
+
+	probe, err := os.CreateTemp(dir, ".plexbot-validate-")
+	if err != nil {
+		return fmt.Errorf("The directory isn't writable: %v", dir)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return nil
+}
+
+// sortedKeys returns the keys of the map in order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedStringKeys returns the keys of the map in order
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// containsString returns true if the slice contains the item 'e'
+func containsString(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
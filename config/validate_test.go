@@ -0,0 +1,200 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixture copies a config from testdata into a temp directory, pointing
+// its library paths at directories that exist
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+
+	raw, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	tv, errors := filepath.Join(dir, "tv"), filepath.Join(dir, "errors")
+	for _, path := range []string{tv, errors} {
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replacer := strings.NewReplacer("{{tv}}", filepath.ToSlash(tv), "{{errors}}", filepath.ToSlash(errors))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(replacer.Replace(string(raw))), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// found is the part of a problem the tests check
+type found struct {
+	Key      string
+	Line     int
+	Severity Severity
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		file string
+		want []found
+	}{
+		{"valid.yaml", nil},
+		{"problems.yaml", []found{
+			{"colour", 6, SeverityWarning},
+			{"plex.tvpth", 5, SeverityWarning},
+			{"postprocess[0].colour", 9, SeverityWarning},
+			{"postprocess[0]", 8, SeverityError},
+			{"postprocessall[0]", 11, SeverityError},
+		}},
+		{"problems.json", []found{
+			{"colour", 7, SeverityWarning},
+			{"plex.tvpth", 5, SeverityWarning},
+			{"postprocess[0].colour", 11, SeverityWarning},
+			{"postprocess[0]", 10, SeverityError},
+			{"postprocessall[0]", 15, SeverityError},
+		}},
+		{"problems.toml", []found{
+			{"colour", 2, SeverityWarning},
+			{"plex.tvpth", 8, SeverityWarning},
+			{"postprocess[0].colour", 12, SeverityWarning},
+			{"postprocess[0]", 11, SeverityError},
+			{"postprocessall[0]", 3, SeverityError},
+		}},
+		{"broken.yaml", []found{{"", 2, SeverityError}}},
+		{"broken.json", []found{{"", 4, SeverityError}}},
+		{"broken.toml", []found{{"", 4, SeverityError}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			path := fixture(t, test.file)
+
+			var got []found
+			for _, problem := range Validate(path) {
+				if problem.File != path {
+					t.Errorf("%v: the problem is for %v, want %v", problem, problem.File, path)
+				}
+				got = append(got, found{problem.Key, problem.Line, problem.Severity})
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("Validate found %+v, want %+v", got, test.want)
+			}
+			for i := range test.want {
+				if got[i] != test.want[i] {
+					t.Errorf("problem %d = %+v, want %+v", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"problems.yaml", []string{"Unknown key", "Unknown key", "Unknown key", "Unknown token {bogus}", "The token {oldfilepath} can't be used in the postprocessall section"}},
+		{"broken.yaml", []string{"Can't parse the config file: yaml: line 2"}},
+		{"broken.json", []string{"Can't parse the config file: invalid character '}'"}},
+		{"broken.toml", []string{"Can't parse the config file: (4, 1)"}},
+	}
+
+	for _, test := range tests {
+		problems := Validate(fixture(t, test.file))
+		if len(problems) != len(test.want) {
+			t.Errorf("%v: Validate found %v, want %d problems", test.file, problems, len(test.want))
+			continue
+		}
+		for i, want := range test.want {
+			if !strings.HasPrefix(problems[i].Message, want) {
+				t.Errorf("%v: problem %d = %q, want it to start with %q", test.file, i, problems[i].Message, want)
+			}
+		}
+	}
+}
+
+func TestValidateFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	missing := Validate(filepath.Join(dir, "plexbot.yaml"))
+	if len(missing) != 1 || !strings.HasPrefix(missing[0].Message, "Can't read the config file") {
+		t.Errorf("Validate for a missing file = %v, want a read error", missing)
+	}
+
+	ini := filepath.Join(dir, "plexbot.ini")
+	if err := os.WriteFile(ini, []byte("[plex]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if problems := Validate(ini); len(problems) != 1 || !strings.Contains(problems[0].Message, "unsupported config file type") {
+		t.Errorf("Validate for an .ini file = %v, want an unsupported type error", problems)
+	}
+}
+
+func TestCheckDoesNotWrite(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+
+	path := fixture(t, "valid.yaml")
+	tv := filepath.Join(filepath.Dir(path), "tv")
+	if err := os.Chmod(tv, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(tv, 0755)
+
+	if problems := Check(path); len(problems) != 0 {
+		t.Errorf("Check = %v, want no problems", problems)
+	}
+
+	problems := Validate(path)
+	if len(problems) != 1 || problems[0].Key != "plex.tvpath" || !strings.Contains(problems[0].Message, "isn't writable") {
+		t.Errorf("Validate = %v, want the TV path to be unwritable", problems)
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Plex: PlexConfig{TVPath: dir, ErrorPath: filepath.Join(dir, "errors")}}
+
+	//	The errors path gets created when it's needed, so it doesn't have to exist
+	if problems := cfg.CheckWritable(); len(problems) != 0 {
+		t.Errorf("CheckWritable = %v, want no problems", problems)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("CheckWritable left %d file(s) behind", len(entries))
+	}
+}
+
+func TestChildLine(t *testing.T) {
+	v := &validator{raw: []byte("name: top\nplex:\n  tvpath: /tv\n  name: child\n")}
+
+	tests := []struct {
+		section int
+		child   string
+		want    int
+	}{
+		{0, "name", 1},
+		{v.keyLine("plex"), "name", 4},
+		{v.keyLine("plex"), "tvpath", 3},
+		{v.keyLine("plex"), "missing", 0},
+	}
+
+	for _, test := range tests {
+		if got := v.childLine(test.section, test.child); got != test.want {
+			t.Errorf("childLine(%d, %q) = %d, want %d", test.section, test.child, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// When holds the conditions for running a plugin.  Every condition that's
+// set has to match for the plugin to run.  Conditions that take a list
+// match if any of the items in the list match
+type When struct {
+	// Tags matches if any of these tags were passed
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty" toml:"tags,omitempty" mapstructure:"tags"`
+
+	// ParseType matches how the filename was parsed: se, date, extra,
+	// movie or unknown
+	ParseType []string `yaml:"parsetype,omitempty" json:"parsetype,omitempty" toml:"parsetype,omitempty" mapstructure:"parsetype"`
+
+	// Show is a regular expression that has to match the show name
+	Show string `yaml:"show,omitempty" json:"show,omitempty" toml:"show,omitempty" mapstructure:"show"`
+
+	// Library matches the library the file was sent to: tv, movies or errors
+	Library []string `yaml:"library,omitempty" json:"library,omitempty" toml:"library,omitempty" mapstructure:"library"`
+
+	// Extension matches the file extension (like .mkv)
+	Extension []string `yaml:"extension,omitempty" json:"extension,omitempty" toml:"extension,omitempty" mapstructure:"extension"`
+
+	// MinSize and MaxSize match the file size (like 100MB or 2GB)
+	MinSize string `yaml:"minsize,omitempty" json:"minsize,omitempty" toml:"minsize,omitempty" mapstructure:"minsize"`
+	MaxSize string `yaml:"maxsize,omitempty" json:"maxsize,omitempty" toml:"maxsize,omitempty" mapstructure:"maxsize"`
+
+	// Outcome matches what happened to the file: moved, error-copied or failed
+	Outcome []string `yaml:"outcome,omitempty" json:"outcome,omitempty" toml:"outcome,omitempty" mapstructure:"outcome"`
+
+	// Previous matches the result of the previous step: success or failure
+	Previous string `yaml:"previous,omitempty" json:"previous,omitempty" toml:"previous,omitempty" mapstructure:"previous"`
+}
+
+var (
+	//	The values the list conditions can have
+	parseTypes = []string{"se", "date", "extra", "movie", "unknown"}
+	libraries  = []string{"tv", "movies", "errors"}
+	outcomes   = []string{"moved", "error-copied", "failed"}
+	previous   = []string{"", "success", "failure"}
+
+	//	The conditions that only make sense for a single file, after it's been parsed
+	fileConditions = []string{"parsetype", "show", "library", "outcome"}
+
+	rxSize = regexp.MustCompile(`(?i)^\s*(\d+(?:\.\d+)?)\s*(b|kb|mb|gb|tb)?\s*$`)
+)
+
+// ParseSize parses a size like 100MB or 1.5GB into bytes
+func ParseSize(size string) (int64, error) {
+	matches := rxSize.FindStringSubmatch(size)
+	if matches == nil {
+		return 0, fmt.Errorf("%q isn't a size (try something like 100MB)", size)
+	}
+
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	multiplier := map[string]float64{
+		"":   1,
+		"b":  1,
+		"kb": 1 << 10,
+		"mb": 1 << 20,
+		"gb": 1 << 30,
+		"tb": 1 << 40,
+	}[strings.ToLower(matches[2])]
+
+	return int64(value * multiplier), nil
+}
+
+// validate checks the conditions for a plugin in the given section
+func (w *When) validate(section, key string) []Problem {
+	var problems []Problem
+	key = key + ".when"
+
+	problems = append(problems, checkValues(key+".parsetype", w.ParseType, parseTypes)...)
+	problems = append(problems, checkValues(key+".library", w.Library, libraries)...)
+	problems = append(problems, checkValues(key+".outcome", w.Outcome, outcomes)...)
+	problems = append(problems, checkValues(key+".previous", []string{w.Previous}, previous)...)
+
+	if w.Show != "" {
+		if _, err := regexp.Compile(w.Show); err != nil {
+			problems = append(problems, Problem{Key: key + ".show", Severity: SeverityError, Message: fmt.Sprintf("The show pattern won't compile: %v", err), needles: []string{w.Show}})
+		}
+	}
+
+	for name, size := range map[string]string{"minsize": w.MinSize, "maxsize": w.MaxSize} {
+		if size == "" {
+			continue
+		}
+		if _, err := ParseSize(size); err != nil {
+			problems = append(problems, Problem{Key: key + "." + name, Severity: SeverityError, Message: err.Error(), needles: []string{size}})
+		}
+	}
+
+	//	Preprocess plugins run before the file is parsed, and postprocessall
+	//	plugins run once for the whole run, so file conditions can't match.
+	//	Premove hooks run before the file has an outcome
+	if section != "postprocess" {
+		set := map[string]bool{
+			"parsetype": len(w.ParseType) > 0,
+			"show":      w.Show != "",
+			"library":   len(w.Library) > 0,
+			"outcome":   len(w.Outcome) > 0,
+		}
+		for _, condition := range fileConditions {
+			if section == "premove" && condition != "outcome" {
+				continue
+			}
+			if set[condition] {
+				problems = append(problems, Problem{Key: key + "." + condition, Severity: SeverityError, Message: fmt.Sprintf("This condition can't be used in the %v section", section), needles: []string{condition + ":"}})
+			}
+		}
+	}
+
+	return problems
+}
+
+// checkValues makes sure each of the values is one we know about
+func checkValues(key string, values, known []string) []Problem {
+	var problems []Problem
+	for _, value := range values {
+		if !containsString(known, strings.ToLower(value)) {
+			problems = append(problems, Problem{Key: key, Severity: SeverityError, Message: fmt.Sprintf("Unknown value %q (use %s)", value, strings.Join(nonEmpty(known), ", ")), needles: []string{value}})
+		}
+	}
+	return problems
+}
+
+// nonEmpty returns the items in the list that aren't empty
+func nonEmpty(list []string) []string {
+	var result []string
+	for _, item := range list {
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -32,10 +32,16 @@ func FindWithExtension(exts []string, baseDirectory string) []string {
 	return files
 }
 
+// HasExtension returns true if the file has one of the given extensions
+func HasExtension(exts []string, file string) bool {
+	return contains(exts, filepath.Ext(file))
+}
+
 // Copy copies the contents from src to dst using io.Copy.
 // If dst does not exist, CopyFile creates it with permissions perm;
 // otherwise CopyFile truncates it before writing.
-func Copy(src, dst string, perm os.FileMode) (err error) {
+// It returns the number of bytes written.
+func Copy(src, dst string, perm os.FileMode) (written int64, err error) {
 	in, err := os.Open(src)
 	if err != nil {
 		return
@@ -50,7 +56,7 @@ func Copy(src, dst string, perm os.FileMode) (err error) {
 			err = e
 		}
 	}()
-	_, err = io.Copy(out, in)
+	written, err = io.Copy(out, in)
 	return
 }
 
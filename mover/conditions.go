@@ -0,0 +1,125 @@
+package mover
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/report"
+)
+
+// The libraries a file can be sent to
+const (
+	libraryTV     = "tv"
+	libraryErrors = "errors"
+	libraryMovies = "movies"
+
+	//	Files for ignored shows aren't sent anywhere
+	libraryIgnored = "ignored"
+)
+
+// The parse types that aren't episodes
+const (
+	//	Plex local extras, like featurettes
+	parseTypeExtra = "extra"
+
+	//	Movies, filed with --movie
+	parseTypeMovie = "movie"
+)
+
+// stepContext is what plugin conditions are matched against
+type stepContext struct {
+	tags      []string
+	parseType string
+	showName  string
+	library   string
+	extension string
+	size      int64
+	outcome   report.Outcome
+
+	//	Whether the previous step (the move, or the previous plugin) failed
+	previousFailed bool
+}
+
+// shouldRun returns true if a plugin in the given stage should run
+// in the given context
+func shouldRun(p config.Plugin, stage string, ctx stepContext) bool {
+	w := p.When
+	if w == nil {
+		w = &config.When{}
+	}
+
+	//	Never delete the source of a file that didn't make it into the library
+	if p.Action == "delete-source" && ctx.outcome != report.OutcomeMoved {
+		return false
+	}
+
+	//	Postprocess plugins without an outcome condition run for the files
+	//	that made it to the move step, like they always have
+	if stage == "postprocess" && len(w.Outcome) == 0 {
+		if ctx.outcome != report.OutcomeMoved && ctx.outcome != report.OutcomeFailed {
+			return false
+		}
+	}
+
+	if len(w.Tags) > 0 && !anyMatch(w.Tags, ctx.tags) {
+		return false
+	}
+
+	if len(w.ParseType) > 0 && !anyMatch(w.ParseType, []string{ctx.parseType}) {
+		return false
+	}
+
+	if w.Show != "" {
+		rx, err := regexp.Compile(w.Show)
+		if err != nil || !rx.MatchString(ctx.showName) {
+			return false
+		}
+	}
+
+	if len(w.Library) > 0 && !anyMatch(w.Library, []string{ctx.library}) {
+		return false
+	}
+
+	if len(w.Extension) > 0 && !anyMatch(w.Extension, []string{ctx.extension, strings.TrimPrefix(ctx.extension, ".")}) {
+		return false
+	}
+
+	if w.MinSize != "" {
+		if min, err := config.ParseSize(w.MinSize); err != nil || ctx.size < min {
+			return false
+		}
+	}
+
+	if w.MaxSize != "" {
+		if max, err := config.ParseSize(w.MaxSize); err != nil || ctx.size > max {
+			return false
+		}
+	}
+
+	if len(w.Outcome) > 0 && !anyMatch(w.Outcome, []string{string(ctx.outcome)}) {
+		return false
+	}
+
+	switch strings.ToLower(w.Previous) {
+	case "success":
+		return !ctx.previousFailed
+	case "failure":
+		return ctx.previousFailed
+	}
+
+	return true
+}
+
+// anyMatch returns true if any of the wanted values are in the list
+// of actual values (ignoring case)
+func anyMatch(wanted, actual []string) bool {
+	for _, w := range wanted {
+		for _, a := range actual {
+			if strings.EqualFold(strings.TrimSpace(w), a) {
+				return true
+			}
+		}
+	}
+	return false
+}
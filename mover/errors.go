@@ -0,0 +1,49 @@
+package mover
+
+import "fmt"
+
+// SourceMissingError indicates the source directory doesn't exist, or
+// that none of the files in the torrent are in it
+type SourceMissingError struct {
+	Path    string
+	Torrent string
+}
+
+func (e *SourceMissingError) Error() string {
+	if e.Torrent != "" {
+		return fmt.Sprintf("None of the files in the torrent %v were found in %v", e.Torrent, e.Path)
+	}
+	return fmt.Sprintf("The directory doesn't exist: %v", e.Path)
+}
+
+// DestinationMissingError indicates the destination library directory doesn't exist
+type DestinationMissingError struct {
+	Path string
+}
+
+func (e *DestinationMissingError) Error() string {
+	return fmt.Sprintf("The plex TV directory doesn't exist: %v", e.Path)
+}
+
+// AbortedError indicates a premove hook stopped the run
+type AbortedError struct {
+	File   string
+	Reason string
+}
+
+func (e *AbortedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("A premove hook stopped the run at %v", e.File)
+	}
+	return fmt.Sprintf("A premove hook stopped the run at %v: %v", e.File, e.Reason)
+}
+
+// OverrideError indicates the overrides would file several files as the
+// same episode, so each would overwrite the last
+type OverrideError struct {
+	Files int
+}
+
+func (e *OverrideError) Error() string {
+	return fmt.Sprintf("Can't force one episode or air date on %d files: they'd all be moved to the same place", e.Files)
+}
@@ -0,0 +1,136 @@
+package mover
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// extraKind is a kind of Plex local extra, with the words that give it away
+type extraKind struct {
+	// folder is the Plex extras folder under the show
+	folder string
+
+	// rx finds the kind in a file name (after the show name)
+	rx *regexp.Regexp
+
+	// folders are the names of the source folders that hold this kind
+	folders []string
+
+	// titles are true for words that can be episode titles too (like
+	// 'The Interview'), so they don't count in episode names
+	titles bool
+}
+
+// folderConfidence is how confident we are in a show name read from a folder
+const folderConfidence = 0.8
+
+var (
+	//	The kinds of extras, in the order they're checked
+	extraKinds = []extraKind{
+		{folder: "Behind The Scenes", rx: regexp.MustCompile(`(?i)\b(?:behind the scenes|making of)\b`), folders: []string{"behind the scenes", "making of"}},
+		{folder: "Deleted Scenes", rx: regexp.MustCompile(`(?i)\bdeleted scenes?\b`), folders: []string{"deleted scenes", "deleted"}},
+		{folder: "Featurettes", rx: regexp.MustCompile(`(?i)\bfeaturettes?\b`), folders: []string{"featurettes", "featurette"}},
+		{folder: "Interviews", rx: regexp.MustCompile(`(?i)\binterviews?\b`), folders: []string{"interviews", "interview"}, titles: true},
+		{folder: "Trailers", rx: regexp.MustCompile(`(?i)\btrailers?\b`), folders: []string{"trailers", "trailer"}, titles: true},
+		{folder: "Other", folders: []string{"extras", "extra", "bonus", "other"}},
+	}
+
+	//	Finds show names in season pack folders, like Show.Name.S01.1080p
+	rxSeasonPack = regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+(?:s\d{1,2}|season[. _-]?\d{1,2}|complete)(?:[. _-]|$)`)
+)
+
+// extraFolder returns the Plex extras folder for the file, or an empty string
+// if it isn't an extra.  Extras are found by words in the file name (after
+// the show name, so 'Interview with the Vampire' isn't an interview) or by
+// the folder they're in.  episode is true if the name parsed as an episode
+func extraFolder(file string, dirs []string, show string, episode bool) string {
+	base := strings.ToLower(cleanName(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))))
+	base = strings.TrimPrefix(base, strings.ToLower(show))
+
+	for _, kind := range extraKinds {
+		if kind.rx != nil && !(episode && kind.titles) && kind.rx.MatchString(base) {
+			return kind.folder
+		}
+	}
+
+	//	The nearest folder that names a kind of extra wins
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := strings.ToLower(cleanName(dirs[i]))
+		for _, kind := range extraKinds {
+			if containsString(kind.folders, dir) {
+				return kind.folder
+			}
+		}
+	}
+
+	return ""
+}
+
+// planExtra fills in the show for an extra, if the file name didn't have one.
+// It's read from the folders the file is in (nearest first), then from the
+// fallback name.  It returns false if there's no show to file the extra under
+func (p *Planner) planExtra(info *plugin.ParseInfo, file string, dirs []string) bool {
+	if info.ParseType != "unknown" && info.ShowName != "" {
+		info.ParseType = parseTypeExtra
+		return true
+	}
+
+	//	Folders that hold extras (like Extras) don't name the show
+	names := []string{}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		names = append(names, dirs[i])
+	}
+	if p.FallbackName != "" {
+		names = append(names, p.FallbackName)
+	}
+
+	for _, name := range names {
+		if show := p.showFromFolder(file, name, dirs); show != "" {
+			info.ParseType = parseTypeExtra
+			info.ShowName = show
+			info.Confidence = folderConfidence
+			info.Parser = "folder"
+			return true
+		}
+	}
+
+	return false
+}
+
+// showFromFolder returns the show a folder (or torrent) name is for, or an
+// empty string if it doesn't name one.  Names like Show.Name.S01E02.720p are
+// parsed like file names, and season packs like Show.Name.S01.1080p are
+// read up to the season
+func (p *Planner) showFromFolder(file, name string, dirs []string) string {
+	if extraFolder(name, nil, "", false) != "" {
+		return ""
+	}
+
+	if info, err := p.parseName(filepath.Join(filepath.Dir(file), name+filepath.Ext(file)), dirs); err == nil && info.ParseType != "unknown" && info.ShowName != "" {
+		return info.ShowName
+	}
+
+	if matches := rxSeasonPack.FindStringSubmatch(name); matches != nil {
+		return properTitle(cleanName(matches[1]))
+	}
+
+	return ""
+}
+
+// cleanName turns the separators in a name into spaces
+func cleanName(name string) string {
+	return strings.Join(strings.Fields(strings.NewReplacer(".", " ", "_", " ", "-", " ").Replace(name)), " ")
+}
+
+// containsString returns true if the list contains the string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,507 @@
+package mover
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/files"
+	"github.com/danesparza/plexbot/plugin"
+	"github.com/danesparza/plexbot/report"
+	"github.com/danesparza/plexbot/webhook"
+)
+
+// MediaExtensions is the list of file extensions that get moved
+var MediaExtensions = []string{".mp4", ".mkv", ".avi"}
+
+// Options are the settings for a single run that don't come from the config
+type Options struct {
+	// SourceDir is the directory to look for files in
+	SourceDir string
+
+	// Hash is the torrent hash used to identify the torrent
+	Hash string
+
+	// Tags is the list of tags passed with the torrent
+	Tags []string
+
+	// Files limits the run to these files, instead of every media
+	// file found in the source directory.  An empty (but not nil)
+	// list means there's nothing to process
+	Files []string
+
+	// FallbackName is parsed if a file name can't be, like the name
+	// of the torrent the files came from
+	FallbackName string
+
+	// Metadata describes the show and episode.  If it's set, it's used
+	// instead of parsing the file names
+	Metadata *plugin.ParseInfo
+
+	// Overrides force parts of what's parsed from the file names, like
+	// the show name
+	Overrides *Overrides
+}
+
+// run holds the state for a single run
+type run struct {
+	cfg     config.Config
+	opts    Options
+	report  *report.Report
+	planner *Planner
+
+	//	The tokens shared by every file in the run.  Each file
+	//	gets its own copy to add its tokens to
+	tokens map[string]string
+
+	//	The files moved and folders touched during the run
+	movedFiles    []string
+	showFolders   []string
+	seasonFolders []string
+
+	//	The file moved to each destination, so two files in the run
+	//	can't overwrite each other
+	destinations map[string]string
+
+	//	Set if a premove hook stopped the run
+	aborted *AbortedError
+}
+
+// Run finds the files in the source directory and moves each of them into
+// the library.  The report is returned even if the run stops early
+func Run(cfg config.Config, opts Options) (*report.Report, error) {
+	runID := newRunID()
+	r := &run{
+		cfg:    cfg,
+		opts:   opts,
+		report: report.New(opts.SourceDir, opts.Hash, strings.Join(opts.Tags, ",")),
+		tokens: map[string]string{
+			"{runid}":     runID,
+			"{hash}":      opts.Hash,
+			"{tvpath}":    cfg.Plex.TVPath,
+			"{errorpath}": cfg.Plex.ErrorPath,
+		},
+		destinations: make(map[string]string),
+	}
+	r.report.RunID = runID
+
+	planner, err := NewPlanner(cfg)
+	if err != nil {
+		r.report.Finish()
+		return r.report, err
+	}
+	r.planner = planner
+	r.planner.FallbackName = opts.FallbackName
+	r.planner.Tags = opts.Tags
+	r.planner.Overrides = opts.Overrides
+
+	err = r.moveFiles()
+	r.report.Finish()
+
+	return r.report, err
+}
+
+// moveFiles finds the files in the source directory and moves each of them
+// into the library, adding the results to the run report
+func (r *run) moveFiles() error {
+	sourceBaseDir := r.opts.SourceDir
+
+	//	If we have a 'noprocess' tag, indicate we found that and we're not going to continue
+	if hasTag(r.opts.Tags, "noprocess") {
+		log.Println("[INFO] Found a 'noprocess' tag, so we won't be continuing to process this file")
+		if _, err := os.Stat(sourceBaseDir); err == nil {
+			for _, file := range r.findFiles() {
+				r.report.Add(report.FileResult{File: file, Outcome: report.OutcomeSkipped, Reason: "Found a 'noprocess' tag"})
+			}
+		}
+		return nil
+	}
+
+	log.Printf("[INFO] Looking for files in: %v...", sourceBaseDir)
+
+	//	See if the source directory exists
+	if _, err := os.Stat(sourceBaseDir); os.IsNotExist(err) {
+		return &SourceMissingError{Path: sourceBaseDir}
+	}
+
+	//	See if the destination directory exists
+	if _, err := os.Stat(r.cfg.Plex.TVPath); err != nil {
+		return &DestinationMissingError{Path: r.cfg.Plex.TVPath}
+	}
+
+	//	If it does, see what movie files it contains:
+	filesToMove := r.findFiles()
+	log.Printf("[INFO] Found %d file(s) to process", len(filesToMove))
+
+	//	Forcing one episode (or air date) on several files would move
+	//	them all to the same place
+	if r.opts.Overrides.single() && len(filesToMove) > 1 {
+		return &OverrideError{Files: len(filesToMove)}
+	}
+
+	for index, file := range filesToMove {
+		r.report.Add(r.moveFile(file))
+
+		//	If a premove hook stopped the run, skip the rest of the files
+		if r.aborted != nil {
+			for _, skipped := range filesToMove[index+1:] {
+				r.report.Add(report.FileResult{File: skipped, Outcome: report.OutcomeSkipped, Reason: "The run was stopped by a premove hook"})
+			}
+			return r.aborted
+		}
+	}
+
+	//	Perform 'postprocess all' items with the tokens for the whole run
+	r.report.Plugins = r.postProcessAll()
+
+	return nil
+}
+
+// findFiles returns the files to process: the ones we were asked to
+// process, or every media file in the source directory
+func (r *run) findFiles() []string {
+	if r.opts.Files != nil {
+		return r.opts.Files
+	}
+	return files.FindWithExtension(MediaExtensions, r.opts.SourceDir)
+}
+
+// postProcessAll runs the 'postprocess all' items with the tokens describing
+// the whole run.  Lists are joined with the OS path list separator (like PATH)
+func (r *run) postProcessAll() []report.PluginResult {
+	r.report.Finish()
+
+	totals := &plugin.RunTotals{
+		MovedFiles:    r.movedFiles,
+		ShowFolders:   r.showFolders,
+		SeasonFolders: r.seasonFolders,
+		Moved:         r.report.Totals.Moved,
+		Failed:        r.report.FailedFiles(),
+		Skipped:       r.report.Totals.Skipped,
+	}
+
+	tokens := copyTokens(r.tokens)
+	separator := string(os.PathListSeparator)
+	tokens["{movedfiles}"] = strings.Join(totals.MovedFiles, separator)
+	tokens["{showfolders}"] = strings.Join(totals.ShowFolders, separator)
+	tokens["{seasonfolders}"] = strings.Join(totals.SeasonFolders, separator)
+	tokens["{movedcount}"] = strconv.Itoa(totals.Moved)
+	tokens["{failedcount}"] = strconv.Itoa(totals.Failed)
+	tokens["{skippedcount}"] = strconv.Itoa(totals.Skipped)
+
+	event := r.newEvent()
+	event.Run.Totals = totals
+
+	ctx := stepContext{tags: r.opts.Tags, previousFailed: r.report.HasFailures()}
+
+	results := r.runPlugins("postprocessall", r.cfg.PostProcessAll, tokens, event, ctx)
+	return append(results, r.sendWebhooks(webhook.EventRunCompleted, event)...)
+}
+
+// moveFile parses a single file and moves it into the library (or the errors
+// path, if it can't be parsed)
+func (r *run) moveFile(file string) report.FileResult {
+	log.Printf("[INFO] - Found file %v...", file)
+	result := report.FileResult{File: file}
+
+	//	Each file gets its own set of tokens
+	tokens := copyTokens(r.tokens)
+	tokens["{oldfilepath}"] = file
+
+	//	Describe the file for any plugins that want the whole event
+	event := r.newEvent()
+	event.File = &plugin.FileInfo{Path: file, Name: filepath.Base(file)}
+	if info, err := os.Stat(file); err == nil {
+		event.File.Size = info.Size()
+	}
+
+	//	Keep track of what plugin conditions can match on
+	ctx := stepContext{tags: r.opts.Tags, extension: filepath.Ext(file), size: event.File.Size}
+
+	//	Perform preprocessing
+	result.Plugins = append(result.Plugins, r.runPlugins("preprocess", r.cfg.PreProcess, tokens, event, ctx)...)
+
+	//	Parse show information (unless we were given it) and build the plan for the file:
+	var plan *plugin.Plan
+	var err error
+	if r.opts.Metadata != nil {
+		plan, err = r.planner.Provided(file, *r.opts.Metadata)
+	} else {
+		plan, err = r.planner.Plan(file, dirsOf(r.opts.SourceDir, file))
+	}
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		result.Outcome = report.OutcomeParseFailed
+		result.Reason = err.Error()
+		event.File.Outcome = string(result.Outcome)
+		result.Plugins = append(result.Plugins, r.sendWebhooks(webhook.EventParseFailed, event)...)
+		return result
+	}
+
+	//	Leave the files for ignored shows where they are
+	if plan.Library == libraryIgnored {
+		return skipIgnored(result, plan)
+	}
+
+	//	Let the premove hooks change the plan
+	planTokens(plan, tokens)
+	if len(r.cfg.PreMove) > 0 {
+		results, v := r.preMove(file, plan, tokens, event, ctx)
+		result.Plugins = append(result.Plugins, results...)
+
+		if v.abort {
+			log.Printf("[INFO] -- A premove hook stopped the run: %v", v.reason)
+			r.aborted = &AbortedError{File: file, Reason: v.reason}
+		} else if v.skip {
+			log.Printf("[INFO] -- A premove hook skipped the file: %v", v.reason)
+		}
+		if v.skip || v.abort {
+			result.Outcome = report.OutcomeSkipped
+			result.Reason = "Skipped by a premove hook"
+			if v.reason != "" {
+				result.Reason = fmt.Sprintf("%v: %v", result.Reason, v.reason)
+			}
+			return result
+		}
+
+		if plan.Library == libraryIgnored {
+			return skipIgnored(result, plan)
+		}
+	}
+
+	ctx.parseType = plan.ParseType
+	ctx.library = plan.Library
+
+	//	Don't let a file overwrite one moved earlier in the run
+	if earlier, ok := r.destinations[plan.Destination]; ok {
+		log.Printf("[ERROR] %v was already moved to %v", earlier, plan.Destination)
+		result.Outcome = report.OutcomeFailed
+		result.Reason = fmt.Sprintf("%v was already moved to %v", earlier, plan.Destination)
+		result.Plugins = append(result.Plugins, r.postProcess(result, tokens, event, ctx)...)
+		return result
+	}
+	r.destinations[plan.Destination] = file
+
+	//	If we can't parse the filename,
+	//	we should move it to a safe place
+	if plan.Library == libraryErrors {
+
+		if plan.Reason != "" {
+			log.Printf("[INFO] -- %v", plan.Reason)
+		}
+
+		//	Format the filename to tuck away to the errors directory:
+		errorFile := plan.Destination
+
+		//	Make sure the errors path exists:
+		os.MkdirAll(filepath.Dir(errorFile), os.ModePerm)
+
+		//	Copy the file to the error files path
+		result.Destination = errorFile
+		written, err := files.Copy(file, errorFile, os.ModePerm)
+		result.Bytes = written
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			result.Outcome = report.OutcomeParseFailed
+			result.Reason = fmt.Sprintf("Couldn't parse the filename or copy it to the errors path: %v", err)
+		} else {
+			result.Outcome = report.OutcomeErrorCopied
+			result.Reason = "Couldn't parse the filename"
+			if plan.Reason != "" {
+				result.Reason = plan.Reason
+			}
+		}
+
+		//	Perform 'postprocess each' items that asked to run for these files
+		result.Plugins = append(result.Plugins, r.postProcess(result, tokens, event, ctx)...)
+
+		return result
+	}
+
+	//	Describe what we parsed for the plugins
+	parsed := plan.ParseInfo
+	event.File.Parse = &parsed
+	ctx.showName = plan.ShowName
+
+	//	Don't overwrite a file that's already in the library
+	newFile := plan.Destination
+	if _, err := os.Lstat(newFile); err == nil {
+		log.Printf("[ERROR] %v is already in the library", newFile)
+		result.Outcome = report.OutcomeFailed
+		result.Reason = fmt.Sprintf("%v is already in the library", newFile)
+		result.Plugins = append(result.Plugins, r.postProcess(result, tokens, event, ctx)...)
+		return result
+	}
+
+	//	Make sure the new path exists:
+	newPath := filepath.Dir(newFile)
+	os.MkdirAll(newPath, os.ModePerm)
+
+	//	Move the file
+	log.Printf("[INFO] -- Moving to %v", newFile)
+	result.Destination = newFile
+	written, err := files.Copy(file, newFile, os.ModePerm)
+	result.Bytes = written
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		result.Outcome = report.OutcomeFailed
+		result.Reason = err.Error()
+	} else {
+		result.Outcome = report.OutcomeMoved
+		r.movedFiles = appendUnique(r.movedFiles, newFile)
+		r.showFolders = appendUnique(r.showFolders, filepath.Dir(newPath))
+		r.seasonFolders = appendUnique(r.seasonFolders, newPath)
+	}
+
+	//	Perform 'postprocess each' items
+	result.Plugins = append(result.Plugins, r.postProcess(result, tokens, event, ctx)...)
+
+	return result
+}
+
+// skipIgnored skips a file for a show that's ignored in the config
+func skipIgnored(result report.FileResult, plan *plugin.Plan) report.FileResult {
+	log.Printf("[INFO] -- Skipping the file: %v", plan.Reason)
+	result.Outcome = report.OutcomeSkipped
+	result.Reason = plan.Reason
+	return result
+}
+
+// postProcess runs the 'postprocess each' items for a file once we know
+// what happened to it
+func (r *run) postProcess(result report.FileResult, tokens map[string]string, event *plugin.Event, ctx stepContext) []report.PluginResult {
+	event.File.Destination = result.Destination
+	event.File.Outcome = string(result.Outcome)
+
+	ctx.outcome = result.Outcome
+	ctx.previousFailed = result.Outcome != report.OutcomeMoved
+
+	results := r.runPlugins("postprocess", r.cfg.PostProcess, tokens, event, ctx)
+	return append(results, r.sendWebhooks(fileEvent(result.Outcome), event)...)
+}
+
+// runPlugins executes each of the given plugins whose conditions match, with
+// the given tokens, and returns their results.  Plugins that ask for it get
+// the event on stdin
+func (r *run) runPlugins(stage string, plugins []config.Plugin, tokens map[string]string, event *plugin.Event, ctx stepContext) []report.PluginResult {
+	var results []report.PluginResult
+
+	event.Stage = stage
+	event.Tokens = tokens
+
+	for _, p := range plugins {
+		item := plugin.FormatTokenizedString(p.Command, tokens)
+		builtin := p.Builtin.Format(tokens)
+		if p.Action != "" {
+			item = builtin.String()
+		}
+
+		//	Skip the plugin if its conditions don't match
+		if !shouldRun(p, stage, ctx) {
+			log.Printf("[INFO] -- Skipping %v", item)
+			results = append(results, report.PluginResult{Stage: stage, Command: item, Skipped: true})
+			continue
+		}
+
+		log.Printf("[INFO] -- Executing %v", item)
+
+		var output string
+		var err error
+		if p.Action != "" {
+			output, err = plugin.RunBuiltin(builtin)
+		} else {
+			var stdin *plugin.Event
+			if p.Stdin == "json" {
+				stdin = event
+			}
+			output, err = plugin.ExecutePlugin(item, tokens, stdin)
+		}
+
+		result := report.PluginResult{Stage: stage, Command: item, Output: output}
+		if err != nil {
+			log.Printf("[ERROR] Problem executing %v: %v", item, err)
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+
+		//	The next plugin can check how this one went
+		ctx.previousFailed = err != nil
+	}
+
+	return results
+}
+
+// newEvent returns a plugin event describing the run
+func (r *run) newEvent() *plugin.Event {
+	tags := r.opts.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return &plugin.Event{
+		Run: plugin.RunInfo{
+			ID:        r.report.RunID,
+			Source:    r.opts.SourceDir,
+			Hash:      r.opts.Hash,
+			Tags:      tags,
+			TVPath:    r.cfg.Plex.TVPath,
+			ErrorPath: r.cfg.Plex.ErrorPath,
+		},
+	}
+}
+
+// copyTokens returns a copy of the tokens, so they can be added to
+// without changing the original
+func copyTokens(tokens map[string]string) map[string]string {
+	result := make(map[string]string, len(tokens))
+	for k, v := range tokens {
+		result[k] = v
+	}
+	return result
+}
+
+// appendUnique adds the item to the list if it isn't already there
+func appendUnique(list []string, item string) []string {
+	for _, existing := range list {
+		if existing == item {
+			return list
+		}
+	}
+	return append(list, item)
+}
+
+// newRunID returns a random ID for a run
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// hasTag returns true if the list of tags contains the given tag
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(strings.TrimSpace(t), tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// properTitle returns the proper title case for a given string
+func properTitle(input string) string {
+	words := strings.Fields(input)
+
+	for index, word := range words {
+		words[index] = strings.Title(word)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,66 @@
+package mover
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/report"
+)
+
+// download writes a file to a new download directory
+func download(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestMoveCollisions(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Config{Plex: config.PlexConfig{TVPath: filepath.Join(dir, "tv"), ErrorPath: filepath.Join(dir, "errors")}}
+	movies := filepath.Join(dir, "movies")
+	if err := os.MkdirAll(cfg.Plex.TVPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		file      string
+		overrides *Overrides
+	}{
+		{"episode", "Show.Name.S01E02.mkv", nil},
+		{"forced episode", "Show.Name.S01E03.mkv", &Overrides{ShowName: "Show Name", SeasonNumber: intPtr(1), EpisodeNumber: intPtr(3)}},
+		{"movie", "Some.Movie.2005.mkv", &Overrides{Movie: true, Library: movies}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			first, err := Run(cfg, Options{SourceDir: download(t, test.file, "first"), Overrides: test.overrides})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(first.Files) != 1 || first.Files[0].Outcome != report.OutcomeMoved {
+				t.Fatalf("First run = %+v, want the file moved", first.Files)
+			}
+			destination := first.Files[0].Destination
+
+			//	The same episode again can't replace the one in the library
+			second, err := Run(cfg, Options{SourceDir: download(t, test.file, "second"), Overrides: test.overrides})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(second.Files) != 1 || second.Files[0].Outcome != report.OutcomeFailed || !strings.Contains(second.Files[0].Reason, "already in the library") {
+				t.Errorf("Second run = %+v, want the file failed as a collision", second.Files)
+			}
+			if contents, err := os.ReadFile(destination); err != nil || string(contents) != "first" {
+				t.Errorf("%v = %q, %v, want the first file kept", destination, contents, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package mover
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/danesparza/plexbot/parser"
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// overrideParser is the parser name for plans with forced values
+const overrideParser = "override"
+
+// Overrides force parts of the plan for every file in a run, for when the
+// parser gets something wrong.  Values that aren't set are left as they
+// were parsed
+type Overrides struct {
+	// ShowName is the show (or, for movies, the title).  A year at
+	// the end, like 'Doctor Who (2005)', is the show's year
+	ShowName string
+
+	// SeasonNumber and EpisodeNumber file the files as episodes.  Files
+	// that weren't parsed as episodes need both
+	SeasonNumber  *int
+	EpisodeNumber *int
+
+	// AiredYear, AiredMonth and AiredDay file the files by air date
+	AiredYear  int
+	AiredMonth int
+	AiredDay   int
+
+	// Library is the path of the library the files go in, instead of
+	// the Plex TV path (or the show's library)
+	Library string
+
+	// Movie files the files as movies, in the library
+	Movie bool
+}
+
+// rxMovie finds the title and year in movie names, like Some.Movie.2005.720p
+var rxMovie = regexp.MustCompile(`^(.+?)[ ._-]+\(?((?:19|20)\d{2})\)?(?:[ ._-]|$)`)
+
+// numbered returns true if the overrides say how the files are numbered,
+// so they aren't renumbered or taken for extras
+func (o *Overrides) numbered() bool {
+	return o != nil && (o.Movie || o.AiredYear != 0 || o.SeasonNumber != nil || o.EpisodeNumber != nil)
+}
+
+// single returns true if the overrides pin the files to a single episode
+// or air date, so only one file can use them
+func (o *Overrides) single() bool {
+	return o != nil && !o.Movie && (o.EpisodeNumber != nil || o.AiredYear != 0)
+}
+
+// apply forces the values that are set on what was parsed from the file
+func (o *Overrides) apply(info *plugin.ParseInfo, file string) {
+	if o == nil {
+		return
+	}
+
+	forced := false
+	if o.ShowName != "" {
+		info.ShowName, info.ShowYear = parser.SplitYear(o.ShowName)
+		forced = true
+	}
+
+	switch {
+	case o.Movie:
+		//	Movies are named by their title and year
+		info.ParseType = parseTypeMovie
+		info.SeasonNumber, info.EpisodeNumber = 0, 0
+		info.AiredYear, info.AiredMonth, info.AiredDay = 0, 0, 0
+		if o.ShowName == "" {
+			info.ShowName, info.ShowYear = movieName(file)
+		}
+		forced = true
+
+	case o.AiredYear != 0:
+		info.ParseType = "date"
+		info.SeasonNumber, info.EpisodeNumber = 0, 0
+		info.AiredYear, info.AiredMonth, info.AiredDay = o.AiredYear, o.AiredMonth, o.AiredDay
+		forced = true
+
+	case o.SeasonNumber != nil || o.EpisodeNumber != nil:
+		//	Without both, we can only fix episodes we parsed
+		if info.ParseType != "se" && (o.SeasonNumber == nil || o.EpisodeNumber == nil) {
+			break
+		}
+		info.ParseType = "se"
+		info.AiredYear, info.AiredMonth, info.AiredDay = 0, 0, 0
+		setInt(&info.SeasonNumber, o.SeasonNumber)
+		setInt(&info.EpisodeNumber, o.EpisodeNumber)
+		forced = true
+	}
+
+	if forced && info.ParseType != "unknown" {
+		info.Parser = overrideParser
+		info.Confidence = 1
+		info.Alternatives = nil
+	}
+}
+
+// movieName returns the title and year of a movie from its file name,
+// like 'Some Movie' and 2005 for Some.Movie.2005.720p.mkv
+func movieName(file string) (string, int) {
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if matches := rxMovie.FindStringSubmatch(base); matches != nil {
+		if title, year := parser.SplitYear(cleanName(matches[1]) + " " + matches[2]); year != 0 {
+			return properTitle(title), year
+		}
+	}
+	return properTitle(cleanName(base)), 0
+}
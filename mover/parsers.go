@@ -0,0 +1,90 @@
+package mover
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/parser"
+	"github.com/danesparza/plexbot/script"
+)
+
+// scriptParserName is the rule name for results from a script
+const scriptParserName = "script"
+
+// scriptParser parses file names with a script's parse function.  Its
+// results are certain, since the script knows best
+type scriptParser struct {
+	script *script.Script
+}
+
+// Name returns the parser's name
+func (s scriptParser) Name() string {
+	return scriptParserName
+}
+
+// Parse calls the script's parse function
+func (s scriptParser) Parse(file parser.File) (*parser.Result, error) {
+	info, err := s.script.Parse(file.Name, file.Dirs)
+	if err != nil || info == nil {
+		return nil, err
+	}
+
+	info.Confidence = 1
+	return &parser.Result{ParseInfo: *info, Rule: scriptParserName}, nil
+}
+
+// scopedRule is a custom parse rule that's only used for files in some
+// directories, or with some tags
+type scopedRule struct {
+	parser.Rule
+	settings config.ParseRule
+}
+
+// Parse matches the rule against the file name, if the rule applies to the file
+func (r scopedRule) Parse(file parser.File) (*parser.Result, error) {
+	if !r.appliesTo(file.Path, file.Tags) {
+		return nil, nil
+	}
+	return r.Rule.Parse(file)
+}
+
+// appliesTo returns true if the rule can be used for the file.  Rules
+// without dirs or tags can be used for any file
+func (r scopedRule) appliesTo(file string, tags []string) bool {
+	if len(r.settings.Tags) > 0 {
+		tagged := false
+		for _, tag := range r.settings.Tags {
+			tagged = tagged || hasTag(tags, tag)
+		}
+		if !tagged {
+			return false
+		}
+	}
+
+	if len(r.settings.Dirs) == 0 {
+		return true
+	}
+
+	for _, dir := range r.settings.Dirs {
+		if isBelow(dir, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBelow returns true if the file is in the directory, or one below it
+func isBelow(dir, file string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(absDir, absFile)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,349 @@
+package mover
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/parser"
+	"github.com/danesparza/plexbot/plugin"
+	"github.com/danesparza/plexbot/script"
+)
+
+// Planner works out where a file should go, without changing the filesystem
+type Planner struct {
+	// FallbackName is parsed if a file name can't be, like the
+	// name of the torrent the file came from
+	FallbackName string
+
+	// Tags are the tags passed with the files, for picking parse rules
+	Tags []string
+
+	// Overrides force parts of the plan, like the show name
+	Overrides *Overrides
+
+	cfg      config.Config
+	script   *script.Script
+	registry *parser.Registry
+}
+
+// NewPlanner returns a planner for the config, loading its script if it has
+// one and compiling its parse rules
+func NewPlanner(cfg config.Config) (*Planner, error) {
+	p := &Planner{cfg: cfg, registry: parser.NewRegistry()}
+
+	if cfg.Script != nil && cfg.Script.Path != "" {
+		s, err := script.Load(cfg.Script.Path)
+		if err != nil {
+			return nil, fmt.Errorf("problem loading the script: %v", err)
+		}
+		p.script = s
+	}
+
+	//	The script gets the first go at parsing file names.  Unless it's
+	//	the only parser, the custom rules go around the built-in ones
+	if p.script != nil && p.script.HasParse() {
+		p.registry.Register(scriptParser{p.script})
+	}
+	if p.scriptOnly() {
+		return p, nil
+	}
+
+	var before, after []parser.Parser
+	for index, settings := range cfg.Parsers {
+		name := settings.Name
+		if name == "" {
+			name = fmt.Sprintf("parsers[%d]", index)
+		}
+
+		rule, err := parser.NewRule(name, settings.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("problem with the parse rule %v: %v", name, err)
+		}
+
+		if settings.Order == config.ParseRuleAfter {
+			after = append(after, scopedRule{Rule: rule, settings: settings})
+		} else {
+			before = append(before, scopedRule{Rule: rule, settings: settings})
+		}
+	}
+
+	for _, group := range [][]parser.Parser{before, parser.Builtin(cfg.DateOrder), after} {
+		for _, each := range group {
+			p.registry.Register(each)
+		}
+	}
+
+	return p, nil
+}
+
+// Plan parses the file name and works out where the file should go.
+// dirs are the directories the file is in, below the source directory
+func (p *Planner) Plan(file string, dirs []string) (*plugin.Plan, error) {
+	info, err := p.parseName(file, dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	//	If the file name doesn't tell us anything, try the fallback
+	if info.ParseType == "unknown" && p.FallbackName != "" {
+		fallbackFile := filepath.Join(filepath.Dir(file), p.FallbackName+filepath.Ext(file))
+		if fallback, err := p.parseName(fallbackFile, dirs); err == nil && fallback.ParseType != "unknown" {
+			info = fallback
+		}
+	}
+
+	//	Values we were told to use win over the ones we parsed
+	p.Overrides.apply(info, file)
+
+	//	Extras go in the show's extras folders, whatever their names
+	//	parsed to
+	episode := info.ParseType == "se" || info.ParseType == "date"
+	if folder := extraFolder(file, dirs, info.ShowName, episode); folder != "" && !p.Overrides.numbered() && p.planExtra(info, file, dirs) {
+		info.Extra = folder
+	}
+
+	//	Renumber the episodes of shows that are numbered differently
+	//	than the metadata agent
+	if _, settings, ok := p.cfg.FindShow(p.showFolder(info), info.ShowName); ok && info.ParseType == "se" && !p.Overrides.numbered() {
+		renumber(info, settings)
+	}
+
+	plan := &plugin.Plan{ParseInfo: *info}
+	if err := p.Route(file, plan); err != nil {
+		return plan, err
+	}
+	p.checkConfidence(file, plan)
+
+	return plan, nil
+}
+
+// checkConfidence sends files we aren't sure about to the errors path to
+// be looked at, rather than under a made-up season
+func (p *Planner) checkConfidence(file string, plan *plugin.Plan) {
+	if plan.Library == libraryTV && plan.Confidence < p.cfg.MinConfidence {
+		plan.Library = libraryErrors
+		plan.Destination = filepath.Join(p.cfg.Plex.ErrorPath, filepath.Base(file))
+		plan.Reason = fmt.Sprintf("Not sure about the filename (%v, with a confidence of %.2f)", parser.Describe(plan.ParseInfo), plan.Confidence)
+	}
+}
+
+// parseName parses the show information from a file name
+func (p *Planner) parseName(file string, dirs []string) (*plugin.ParseInfo, error) {
+	result, err := p.registry.Parse(parser.NewFile(file, dirs, p.Tags))
+	if err != nil {
+		return nil, err
+	}
+
+	//	Scripts name shows the way they want
+	info := result.ParseInfo
+	info.Parser = result.Rule
+	if result.Rule != scriptParserName {
+		info.ShowName = properTitle(info.ShowName)
+	}
+	for i := range info.Alternatives {
+		info.Alternatives[i].ShowName = properTitle(info.Alternatives[i].ShowName)
+	}
+	return &info, nil
+}
+
+// Provided builds the plan for a file from show information we were given,
+// rather than parsing the file name
+func (p *Planner) Provided(file string, info plugin.ParseInfo) (*plugin.Plan, error) {
+	//	We were told what the file is, so we're sure of it
+	plan := &plugin.Plan{ParseInfo: info}
+	if plan.Confidence == 0 {
+		plan.Confidence = 1
+	}
+	return plan, p.Route(file, plan)
+}
+
+// Route sets the library and destination for the plan, based on what
+// was parsed from the filename
+func (p *Planner) Route(file string, plan *plugin.Plan) error {
+	plan.Reason = ""
+	invalidDate := plan.ParseType == "date" && !parser.ValidDate(plan.AiredYear, plan.AiredMonth, plan.AiredDay)
+	invalidEpisode := plan.ParseType == "se" && (plan.SeasonNumber < 0 || plan.EpisodeNumber < 0)
+	unsafeName := plan.ShowName != "" && !safeName(plan.ShowName)
+
+	if plan.ParseType == "unknown" || plan.ShowName == "" || invalidDate || invalidEpisode || unsafeName {
+		//	Files we can't parse (or with air dates that don't
+		//	exist) get tucked away in the errors path
+		plan.Library = libraryErrors
+		plan.Destination = filepath.Join(p.cfg.Plex.ErrorPath, filepath.Base(file))
+		switch {
+		case unsafeName:
+			plan.Reason = fmt.Sprintf("The show name %q can't be used as a folder name", plan.ShowName)
+		case invalidEpisode:
+			plan.Reason = fmt.Sprintf("The season or episode is less than zero (%v)", parser.Describe(plan.ParseInfo))
+		}
+	} else if plan.ParseType == parseTypeMovie {
+		//	Movies go in their own folder, named by their title and year
+		name := plan.ShowName
+		if plan.ShowYear != 0 {
+			name = fmt.Sprintf("%v (%d)", plan.ShowName, plan.ShowYear)
+		}
+		plan.Library = libraryMovies
+		plan.Destination = filepath.Join(p.library(p.cfg.Plex.TVPath), name, name+filepath.Ext(file))
+	} else {
+		show := p.showFolder(&plan.ParseInfo)
+		_, settings, _ := p.cfg.FindShow(show, plan.ShowName)
+		if settings.Ignore {
+			plan.Library = libraryIgnored
+			plan.Destination = ""
+			plan.Reason = fmt.Sprintf("%v is ignored in the shows section of the config", show)
+			return nil
+		}
+
+		//	Some shows go in their own library
+		plan.Library = libraryTV
+		library := p.cfg.Plex.TVPath
+		if settings.Library != "" {
+			library = settings.Library
+		}
+		library = p.library(library)
+		showDir := filepath.Join(library, show)
+
+		if plan.ParseType == parseTypeExtra {
+			//	Extras keep their names, so Plex can use them as titles
+			plan.Destination = filepath.Join(showDir, plan.Extra, filepath.Base(file))
+		} else if settings.Naming != "" {
+			//	The show has its own naming scheme.  It can't put the
+			//	file outside the library
+			plan.Destination = filepath.Join(library, filepath.FromSlash(formatNaming(settings.Naming, show, file, plan)))
+			if rel, err := filepath.Rel(library, plan.Destination); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				plan.Library = libraryErrors
+				plan.Destination = filepath.Join(p.cfg.Plex.ErrorPath, filepath.Base(file))
+				plan.Reason = fmt.Sprintf("The naming for %v would put the file outside the library", show)
+			}
+		} else if plan.ParseType == "date" {
+			//	If we don't have season or episode, but have 'aired year'
+			//	use the year as the season
+			seasonDir := fmt.Sprintf("Season %d", plan.AiredYear)
+			newFileName := fmt.Sprintf("%v %d-%02d-%02d%v", show, plan.AiredYear, plan.AiredMonth, plan.AiredDay, filepath.Ext(file))
+			plan.Destination = filepath.Join(showDir, seasonDir, newFileName)
+		} else {
+			//	We most likely have a traditional season/episode format.
+			//	Season 0 is for specials
+			seasonDir := fmt.Sprintf("Season %d", plan.SeasonNumber)
+			if plan.SeasonNumber == 0 {
+				seasonDir = p.specialsFolder()
+			}
+			newFileName := fmt.Sprintf("s%de%02d%v", plan.SeasonNumber, plan.EpisodeNumber, filepath.Ext(file))
+			plan.Destination = filepath.Join(showDir, seasonDir, newFileName)
+		}
+	}
+
+	//	Let the script change where the file goes
+	if p.script == nil {
+		return nil
+	}
+
+	destination, err := p.script.Destination(plan, filepath.Base(file), filepath.Ext(file), p.cfg.Plex.TVPath)
+	if err != nil || destination == "" {
+		return err
+	}
+
+	if !filepath.IsAbs(destination) {
+		destination = filepath.Join(p.cfg.Plex.TVPath, destination)
+	}
+	plan.Destination = destination
+	plan.Library = libraryTV
+
+	return nil
+}
+
+// showFolder returns the name of the show's folder, with the year for
+// reboots and remakes (like 'Doctor Who (2005)').  With showyear set to
+// always, shows without a year get the year of an existing folder
+func (p *Planner) showFolder(info *plugin.ParseInfo) string {
+	switch p.cfg.Plex.ShowYear {
+	case config.ShowYearNever:
+		return info.ShowName
+	case config.ShowYearAlways:
+		if info.ShowYear == 0 {
+			info.ShowYear = p.existingYear(info.ShowName)
+		}
+	}
+
+	if info.ShowYear == 0 {
+		return info.ShowName
+	}
+	return fmt.Sprintf("%v (%d)", info.ShowName, info.ShowYear)
+}
+
+// existingYear returns the year of the show's folder in the TV path, like
+// 2005 for 'Doctor Who (2005)'.  If there isn't one, or there's more than
+// one (so we can't tell which is meant), it returns 0
+func (p *Planner) existingYear(show string) int {
+	folders, err := os.ReadDir(p.cfg.Plex.TVPath)
+	if err != nil {
+		return 0
+	}
+
+	year := 0
+	for _, folder := range folders {
+		if !folder.IsDir() || !strings.HasPrefix(strings.ToLower(folder.Name()), strings.ToLower(show)+" (") {
+			continue
+		}
+
+		name, folderYear := parser.SplitYear(folder.Name())
+		if folderYear == 0 || !strings.EqualFold(name, show) {
+			continue
+		}
+		if year != 0 {
+			return 0
+		}
+		year = folderYear
+	}
+	return year
+}
+
+// safeName returns true if the name can be used as a single folder name:
+// it can't have path separators in it, or be '.' or '..', so it can't
+// point outside the library
+func safeName(name string) bool {
+	if strings.ContainsAny(name, `/\`+"\x00") {
+		return false
+	}
+	return strings.Trim(strings.TrimSpace(name), ".") != ""
+}
+
+// library returns the library path to use: the one from the overrides, if
+// there is one, or the given one
+func (p *Planner) library(library string) string {
+	if p.Overrides != nil && p.Overrides.Library != "" {
+		return p.Overrides.Library
+	}
+	return library
+}
+
+// specialsFolder returns the name of the folder for season 0
+func (p *Planner) specialsFolder() string {
+	if p.cfg.Plex.Specials != "" {
+		return p.cfg.Plex.Specials
+	}
+	return config.DefaultSpecials
+}
+
+// Script returns the planner's script, or nil if it doesn't have one
+func (p *Planner) Script() *script.Script {
+	return p.script
+}
+
+// scriptOnly returns true if the script's parse function replaces the
+// built-in parser
+func (p *Planner) scriptOnly() bool {
+	return p.script != nil && p.script.HasParse() && p.cfg.Script.Parse == config.ScriptParseInstead
+}
+
+// dirsOf returns the directories a file is in, below the source directory
+func dirsOf(sourceDir, file string) []string {
+	rel, err := filepath.Rel(sourceDir, filepath.Dir(file))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return []string{}
+	}
+	return strings.Split(filepath.ToSlash(rel), "/")
+}
@@ -0,0 +1,37 @@
+package mover
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/danesparza/plexbot/plugin"
+)
+
+func TestProvidedMovie(t *testing.T) {
+	r := testRun(t)
+	movies := filepath.Join(t.TempDir(), "movies")
+	r.planner.Overrides = &Overrides{Library: movies}
+
+	tests := []struct {
+		name        string
+		info        plugin.ParseInfo
+		wantLibrary string
+		want        string
+	}{
+		{"title and year", plugin.ParseInfo{ParseType: parseTypeMovie, ShowName: "Some Movie", ShowYear: 2005}, libraryMovies, filepath.Join(movies, "Some Movie (2005)", "Some Movie (2005).mkv")},
+		{"no year", plugin.ParseInfo{ParseType: parseTypeMovie, ShowName: "Some Movie"}, libraryMovies, filepath.Join(movies, "Some Movie", "Some Movie.mkv")},
+		{"unsafe title", plugin.ParseInfo{ParseType: parseTypeMovie, ShowName: ".."}, libraryErrors, filepath.Join(r.cfg.Plex.ErrorPath, "file.mkv")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan, err := r.planner.Provided("/downloads/file.mkv", test.info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.Library != test.wantLibrary || plan.Destination != test.want {
+				t.Errorf("Provided = %v %q, want %v %q", plan.Library, plan.Destination, test.wantLibrary, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package mover
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/danesparza/plexbot/parser"
+	"github.com/danesparza/plexbot/plugin"
+	"github.com/danesparza/plexbot/report"
+)
+
+// verdict is what the premove hooks decided to do with a file
+type verdict struct {
+	skip   bool
+	abort  bool
+	reason string
+}
+
+// planTokens sets the show tokens from the plan
+func planTokens(plan *plugin.Plan, tokens map[string]string) {
+	tokens["{newfilepath}"] = plan.Destination
+
+	switch plan.ParseType {
+	case "date":
+		tokens["{showname}"] = plan.ShowName
+		tokens["{showseasonnumber}"] = strconv.Itoa(plan.AiredYear)
+		tokens["{showepisodenumber}"] = fmt.Sprintf("%v-%v-%v", plan.AiredYear, plan.AiredMonth, plan.AiredDay)
+	case "se":
+		tokens["{showname}"] = plan.ShowName
+		tokens["{showseasonnumber}"] = strconv.Itoa(plan.SeasonNumber)
+		tokens["{showepisodenumber}"] = strconv.Itoa(plan.EpisodeNumber)
+	case parseTypeExtra, parseTypeMovie:
+		tokens["{showname}"] = plan.ShowName
+	}
+}
+
+// preMove runs the premove hooks for a file.  Each hook gets the plan (as
+// changed by the hooks before it) and can reply to change it, skip the file
+// or abort the run
+func (r *run) preMove(file string, plan *plugin.Plan, tokens map[string]string, event *plugin.Event, ctx stepContext) ([]report.PluginResult, verdict) {
+	var results []report.PluginResult
+
+	event.Stage = "premove"
+	event.Tokens = tokens
+	event.Plan = plan
+	defer func() { event.Plan = nil }()
+
+	for _, p := range r.cfg.PreMove {
+		planTokens(plan, tokens)
+		item := plugin.FormatTokenizedString(p.Command, tokens)
+
+		//	Conditions match against the plan so far
+		ctx.parseType = plan.ParseType
+		ctx.showName = plan.ShowName
+		ctx.library = plan.Library
+		if !shouldRun(p, "premove", ctx) {
+			log.Printf("[INFO] -- Skipping %v", item)
+			results = append(results, report.PluginResult{Stage: "premove", Command: item, Skipped: true})
+			continue
+		}
+
+		log.Printf("[INFO] -- Executing %v", item)
+		stdout, stderr, err := plugin.ExecuteHook(item, tokens, event)
+
+		result := report.PluginResult{Stage: "premove", Command: item, Output: trimOutput(stdout, stderr)}
+		var reply plugin.PlanReply
+		if err == nil {
+			reply, err = plugin.ParseReply(stdout)
+		}
+		if err != nil {
+			//	The plan is left alone if the hook doesn't work
+			log.Printf("[ERROR] Problem executing %v: %v", item, err)
+			result.Error = err.Error()
+			results = append(results, result)
+			ctx.previousFailed = true
+			continue
+		}
+		results = append(results, result)
+		ctx.previousFailed = false
+
+		if reply.Abort || reply.Skip {
+			return results, verdict{skip: reply.Skip, abort: reply.Abort, reason: reply.Reason}
+		}
+
+		if err := r.applyReply(file, plan, reply); err != nil {
+			log.Printf("[ERROR] Problem applying the reply from %v: %v", item, err)
+			results[len(results)-1].Error = err.Error()
+		}
+	}
+
+	planTokens(plan, tokens)
+	return results, verdict{}
+}
+
+// applyReply changes the plan with the fields set in a premove hook's reply.
+// A reply that doesn't change the show leaves the plan where it was
+func (r *run) applyReply(file string, plan *plugin.Plan, reply plugin.PlanReply) error {
+	numbered := reply.SeasonNumber != nil || reply.EpisodeNumber != nil
+	dated := reply.AiredYear != nil || reply.AiredMonth != nil || reply.AiredDay != nil
+
+	if reply.ShowName != nil || reply.ShowYear != nil || numbered || dated {
+		if reply.ShowName != nil {
+			plan.ShowName, plan.ShowYear = parser.SplitYear(*reply.ShowName)
+		}
+		setInt(&plan.ShowYear, reply.ShowYear)
+
+		if numbered {
+			plan.ParseType = "se"
+			setInt(&plan.SeasonNumber, reply.SeasonNumber)
+			setInt(&plan.EpisodeNumber, reply.EpisodeNumber)
+		}
+
+		if dated {
+			plan.ParseType = "date"
+			setInt(&plan.AiredYear, reply.AiredYear)
+			setInt(&plan.AiredMonth, reply.AiredMonth)
+			setInt(&plan.AiredDay, reply.AiredDay)
+		}
+
+		//	The hook is sure of the episode it gave us, but a show name
+		//	alone doesn't make a guessed episode any more likely
+		if numbered || dated {
+			plan.Confidence = 1
+		}
+
+		//	A show name alone isn't enough to file an unparsed file
+		if err := r.planner.Route(file, plan); err != nil {
+			return err
+		}
+		r.planner.checkConfidence(file, plan)
+	}
+
+	if reply.Destination != nil && *reply.Destination != "" {
+		plan.Destination = *reply.Destination
+		if !filepath.IsAbs(plan.Destination) {
+			plan.Destination = filepath.Join(r.cfg.Plex.TVPath, plan.Destination)
+		}
+		plan.Library = libraryTV
+	}
+
+	return nil
+}
+
+// setInt sets the value if the reply included it
+func setInt(value *int, reply *int) {
+	if reply != nil {
+		*value = *reply
+	}
+}
+
+// trimOutput joins a plugin's stdout and stderr for the report
+func trimOutput(stdout, stderr string) string {
+	return strings.TrimSpace(stdout + stderr)
+}
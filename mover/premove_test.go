@@ -0,0 +1,93 @@
+package mover
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// testRun returns a run with a planner for a config that only files
+// names it's sure of
+func testRun(t *testing.T) *run {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := config.Config{
+		Plex:          config.PlexConfig{TVPath: filepath.Join(dir, "tv"), ErrorPath: filepath.Join(dir, "errors")},
+		MinConfidence: 0.9,
+	}
+
+	planner, err := NewPlanner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &run{cfg: cfg, planner: planner}
+}
+
+func intPtr(i int) *int          { return &i }
+func stringPtr(s string) *string { return &s }
+
+func TestApplyReplyKeepsUnsureFiles(t *testing.T) {
+	//	The loose format isn't sure what 102 is
+	file := "/downloads/Show.Name.102.hdtv.mkv"
+
+	tests := []struct {
+		name        string
+		reply       plugin.PlanReply
+		wantLibrary string
+	}{
+		{"empty reply", plugin.PlanReply{}, libraryErrors},
+		{"show name only", plugin.PlanReply{ShowName: stringPtr("Show Name")}, libraryErrors},
+		{"season and episode", plugin.PlanReply{SeasonNumber: intPtr(1), EpisodeNumber: intPtr(2)}, libraryTV},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := testRun(t)
+			plan, err := r.planner.Plan(file, []string{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.Library != libraryErrors || plan.Reason == "" {
+				t.Fatalf("Plan = %v (%q), want the errors path with a reason", plan.Library, plan.Reason)
+			}
+			reason := plan.Reason
+
+			if err := r.applyReply(file, plan, test.reply); err != nil {
+				t.Fatal(err)
+			}
+
+			if plan.Library != test.wantLibrary {
+				t.Errorf("library = %v, want %v", plan.Library, test.wantLibrary)
+			}
+			if test.wantLibrary == libraryErrors {
+				if plan.Reason != reason {
+					t.Errorf("reason = %q, want %q", plan.Reason, reason)
+				}
+				if !strings.HasPrefix(plan.Destination, r.cfg.Plex.ErrorPath) {
+					t.Errorf("destination = %v, want it in %v", plan.Destination, r.cfg.Plex.ErrorPath)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyReplyProvided(t *testing.T) {
+	//	Show information we were given is filed, even if a hook renames the show
+	r := testRun(t)
+	file := "/downloads/episode.mkv"
+	plan, err := r.planner.Provided(file, plugin.ParseInfo{ParseType: "se", ShowName: "Show Name", SeasonNumber: 1, EpisodeNumber: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.applyReply(file, plan, plugin.PlanReply{ShowName: stringPtr("Other Show")}); err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(r.cfg.Plex.TVPath, "Other Show", "Season 1", "s1e02.mkv"); plan.Library != libraryTV || plan.Destination != want {
+		t.Errorf("plan = %v %v, want %v %v", plan.Library, plan.Destination, libraryTV, want)
+	}
+}
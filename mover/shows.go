@@ -0,0 +1,78 @@
+package mover
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/danesparza/plexbot/config"
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// renumber maps an episode from the way it was released to the way the
+// metadata agent numbers it, using the show's settings.  Absolute numbers
+// are mapped to a season first, then the offsets are added
+func renumber(info *plugin.ParseInfo, show config.Show) {
+	//	Absolute numbers are parsed as season 1 episodes
+	if info.SeasonNumber == 1 {
+		for season := len(show.Absolute); season > 0; season-- {
+			if start := show.Absolute[season-1]; info.EpisodeNumber >= start {
+				info.SeasonNumber = season
+				info.EpisodeNumber = info.EpisodeNumber - start + 1
+				break
+			}
+		}
+	}
+
+	info.SeasonNumber += show.SeasonOffset
+	info.EpisodeNumber += show.EpisodeOffset
+}
+
+// formatNaming fills in a show's naming template for the file.  Shows filed
+// by air date use the year as the season, and the air date as the episode.
+// The values come from release names, so they can't add folders
+func formatNaming(naming, show, file string, plan *plugin.Plan) string {
+	tokens := map[string]string{
+		"{show}":      show,
+		"{showname}":  plan.ShowName,
+		"{showyear}":  "",
+		"{title}":     plan.Title,
+		"{quality}":   plan.Quality,
+		"{filename}":  strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
+		"{ext}":       filepath.Ext(file),
+		"{airdate}":   "",
+		"{season}":    strconv.Itoa(plan.SeasonNumber),
+		"{season00}":  fmt.Sprintf("%02d", plan.SeasonNumber),
+		"{episode}":   strconv.Itoa(plan.EpisodeNumber),
+		"{episode00}": fmt.Sprintf("%02d", plan.EpisodeNumber),
+	}
+
+	if plan.ShowYear != 0 {
+		tokens["{showyear}"] = strconv.Itoa(plan.ShowYear)
+	}
+
+	if plan.ParseType == "date" {
+		airdate := fmt.Sprintf("%d-%02d-%02d", plan.AiredYear, plan.AiredMonth, plan.AiredDay)
+		tokens["{airdate}"] = airdate
+		tokens["{season}"] = strconv.Itoa(plan.AiredYear)
+		tokens["{season00}"] = strconv.Itoa(plan.AiredYear)
+		tokens["{episode}"] = airdate
+		tokens["{episode00}"] = airdate
+	}
+
+	for token, value := range tokens {
+		tokens[token] = namingValue(value)
+	}
+	return plugin.FormatTokenizedString(naming, tokens)
+}
+
+// namingValue makes a value safe to use in a file or folder name: path
+// separators are replaced, and a value that's only dots is dropped
+func namingValue(value string) string {
+	value = strings.NewReplacer("/", "-", `\`, "-", "\x00", "").Replace(value)
+	if strings.Trim(value, ". ") == "" {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,55 @@
+package mover
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/danesparza/plexbot/plugin"
+	"github.com/danesparza/plexbot/report"
+	"github.com/danesparza/plexbot/webhook"
+)
+
+// sendWebhooks sends each of the webhooks that fire on the given event,
+// and returns their results
+func (r *run) sendWebhooks(name string, event *plugin.Event) []report.PluginResult {
+	var results []report.PluginResult
+
+	event.Stage = "webhook"
+
+	for _, hook := range r.cfg.Webhooks {
+		if !hook.FiresOn(name) {
+			continue
+		}
+
+		method := hook.Method
+		if method == "" {
+			method = "POST"
+		}
+		item := fmt.Sprintf("%v %v (%v)", strings.ToUpper(method), hook.URL, name)
+		log.Printf("[INFO] -- Sending webhook %v", item)
+
+		output, err := hook.Send(webhook.Data{Name: name, Event: event})
+		result := report.PluginResult{Stage: "webhook", Command: item, Output: output}
+		if err != nil {
+			log.Printf("[ERROR] Problem sending webhook %v: %v", item, err)
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// fileEvent returns the webhook event for a file outcome
+func fileEvent(outcome report.Outcome) string {
+	switch outcome {
+	case report.OutcomeMoved:
+		return webhook.EventFileMoved
+	case report.OutcomeFailed:
+		return webhook.EventFileFailed
+	case report.OutcomeParseFailed, report.OutcomeErrorCopied:
+		return webhook.EventParseFailed
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// Date orders, for settling dates like 05.03.2024 that could be
+// either way around
+const (
+	DateOrderDMY = "dmy"
+	DateOrderMDY = "mdy"
+)
+
+// DateOrders are the date orders that can be configured
+var DateOrders = []string{DateOrderDMY, DateOrderMDY}
+
+// Confidences for the season and episode formats.  The loose one matches a
+// lot of things that aren't episodes (like Movie.2012.mkv)
+const (
+	strictConfidence = 0.95
+	looseConfidence  = 0.3
+)
+
+// Month names, long and short
+const months = `(?P<monthname>jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sept?(?:ember)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)`
+
+var (
+	//	The extra formats, in the order they're tried (after the strict
+	//	season and episode format).  Dates go first, so their numbers
+	//	aren't mistaken for episodes
+	rules = []Rule{
+		//	Show.2024.03.05
+		{name: "date", confidence: 0.9, rx: regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+(?P<year>(?:19|20)\d{2})[. _-](?P<month>\d{1,2})[. _-](?P<day>\d{1,2})(?:\D|$)`)},
+
+		//	Show.05.03.2024 (day or month first, depending on the date order)
+		{name: "date-dmy", confidence: 0.85, ambiguous: true, rx: regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+(?P<day>\d{1,2})[. _-](?P<month>\d{1,2})[. _-](?P<year>(?:19|20)\d{2})(?:\D|$)`)},
+
+		//	Show.20240305
+		{name: "date-yyyymmdd", confidence: 0.8, rx: regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+(?P<year>(?:19|20)\d{2})(?P<month>[01]\d)(?P<day>[0-3]\d)(?:\D|$)`)},
+
+		//	Show.Jan.5.2024, Show January 5th, 2024
+		{name: "date-month-day", confidence: 0.9, rx: regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+` + months + `\.?[. _-]*(?P<day>\d{1,2})(?:st|nd|rd|th)?[. _,-]+(?P<year>(?:19|20)\d{2})(?:\D|$)`)},
+
+		//	Show 5th January 2024, Show.5.Jan.2024
+		{name: "date-day-month", confidence: 0.9, rx: regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+(?P<day>\d{1,2})(?:st|nd|rd|th)?[. _-]*` + months + `\.?[. _,-]+(?P<year>(?:19|20)\d{2})(?:\D|$)`)},
+
+		//	Show 1x02, Show.1x02.HDTV
+		{name: "1x02", confidence: 0.85, rx: regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+(?P<season>\d{1,2})x(?P<episode>\d{1,3})(?:[. _\[(-]|$)`)},
+
+		//	Show Season 1 Episode 2, Show.S1.Ep.2
+		{name: "season-episode", confidence: 0.9, rx: regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+(?:season|series|s)[. _-]*(?P<season>\d{1,2})[. _,-]*(?:episode|ep)[. _-]*(?P<episode>\d{1,3})(?:\D|$)`)},
+
+		//	Show - Ep05, Show.Episode.5
+		{name: "ep", confidence: 0.7, rx: regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+(?:episode|ep)[. _-]*(?P<episode>\d{1,3})(?:\D|$)`)},
+
+		//	Show Part 2, Show.Pt.2
+		{name: "part", confidence: 0.6, rx: regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+(?:part|pt)[. _-]*(?P<episode>\d{1,2})(?:\D|$)`)},
+
+		//	Show.102.hdtv (season 1, episode 2).  Resolutions like 720p
+		//	don't match, since they're followed by a letter
+		{name: "3-digit", confidence: 0.5, rx: regexp.MustCompile(`(?i)^(?P<show>.+?)[. _-]+(?P<season>[1-9])(?P<episode>\d{2})(?:[. _\[(-]|$)`)},
+	}
+
+	//	dlshow's season and episode formats.  The first is strict, but the
+	//	second matches almost any name with a run of digits in it
+	rxSE  = regexp.MustCompile(`(?i)^((?P<series_name>.+?)[. _-]+)?s(?P<season_num>\d+)[. _-]*e(?P<ep_num>\d+)(([. _-]*e|-)(?P<extra_ep_num>(!(1080|720)[pi])\d+))*[. _-]*((?P<extra_info>.+?)((![. _-])-(?P<release_group>[^-]+))?)?$`)
+	rxSE2 = regexp.MustCompile(`(?P<series_name>.*?)\.S?(?P<season_num>\d{1,2})[Ex-]?(?P<ep_num>\d{2})\.(.*)`)
+
+	//	dlshow's air date format.  It's too loose to use (Show.2012.1080p
+	//	is 2012-10-0), but names it matches aren't read with rxSE2
+	rxD = regexp.MustCompile(`^((?P<series_name>.+?)[. _-]+)(?P<year>\d{4}).(?P<month>\d{1,2}).(?P<day>\d{1,2})`)
+
+	//	Show name formatter
+	rxShow = regexp.MustCompile(`[\W]|_`)
+)
+
+// Builtin returns the built-in parsers, in the order they're tried: the
+// strict season and episode format (S01E02), air dates, the extended
+// formats, then the loose season and episode format.  dateOrder is how
+// dates like 05.03.2024 are read: day first (dmy, the default) or month
+// first (mdy)
+func Builtin(dateOrder string) []Parser {
+	parsers := []Parser{seParser{name: "sxxeyy", rx: rxSE, confidence: strictConfidence}}
+
+	for _, r := range rules {
+		r.dateOrder = dateOrder
+		parsers = append(parsers, r)
+	}
+
+	return append(parsers, seParser{name: "sxxeyy-loose", rx: rxSE2, confidence: looseConfidence, loose: true})
+}
+
+// seParser reads the season and episode formats that plexbot has always
+// understood (they used to come from dlshow)
+type seParser struct {
+	name       string
+	rx         *regexp.Regexp
+	confidence float64
+	loose      bool
+}
+
+// Name returns the parser's name
+func (p seParser) Name() string {
+	return p.name
+}
+
+// Parse reads the season and episode from the file name (with its extension)
+func (p seParser) Parse(file File) (*Result, error) {
+	if p.loose {
+		//	Leave names in the other formats alone, even if their
+		//	dates aren't real (like 31.02.2024)
+		if rxSE.MatchString(file.Name) || rxD.MatchString(file.Name) || looksLikeDate(file.Base) {
+			return nil, nil
+		}
+	}
+
+	matches := p.rx.FindStringSubmatch(file.Name)
+	if matches == nil {
+		return nil, nil
+	}
+
+	groups := make(map[string]string)
+	for i, group := range p.rx.SubexpNames() {
+		if group != "" {
+			groups[group] = matches[i]
+		}
+	}
+
+	result := &Result{Rule: p.name, ParseInfo: plugin.ParseInfo{ParseType: "se", ShowName: cleanShow(groups["series_name"]), Confidence: p.confidence}}
+	result.SeasonNumber, _ = strconv.Atoi(groups["season_num"])
+	result.EpisodeNumber, _ = strconv.Atoi(groups["ep_num"])
+
+	//	Numbers like 2012 are more likely to be a year than
+	//	season 20, episode 12
+	if year := result.SeasonNumber*100 + result.EpisodeNumber; p.loose && year >= 1900 && year < 2100 {
+		result.Confidence /= 2
+	}
+
+	return result, nil
+}
+
+// looksLikeDate returns true if the file name is in one of the date
+// formats, whether or not the date is real
+func looksLikeDate(name string) bool {
+	for _, r := range rules {
+		if r.date() && r.rx.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanShow turns the separators in a show name into spaces
+func cleanShow(show string) string {
+	return strings.Join(strings.Fields(rxShow.ReplaceAllString(show, " ")), " ")
+}
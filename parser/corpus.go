@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// Case is a single release name from a corpus, with what it should parse to
+type Case struct {
+	Line int
+	Name string
+	Want plugin.ParseInfo
+
+	// Destination is where the file should go, relative to the TV path
+	// ('(errors)' for the errors path).  It isn't checked if it's empty
+	Destination string
+}
+
+// LoadCorpus reads a tab separated corpus file.  Each line has a release name,
+// the parse type (se, date, extra or unknown), the show name, the season, the
+// episode, the air date (as YYYY-MM-DD) and the destination.  Blank lines and lines
+// starting with # are ignored, and trailing columns can be left off
+func LoadCorpus(path string) ([]Case, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cases []Case
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		columns := strings.Split(text, "\t")
+		for len(columns) < 7 {
+			columns = append(columns, "")
+		}
+
+		c := Case{Line: line, Name: columns[0], Destination: columns[6]}
+		c.Want.ParseType = columns[1]
+		c.Want.ShowName, c.Want.ShowYear = SplitYear(columns[2])
+
+		if columns[3] != "" {
+			if c.Want.SeasonNumber, err = strconv.Atoi(columns[3]); err != nil {
+				return nil, fmt.Errorf("%v:%d: the season %q isn't a number", path, line, columns[3])
+			}
+		}
+		if columns[4] != "" {
+			if c.Want.EpisodeNumber, err = strconv.Atoi(columns[4]); err != nil {
+				return nil, fmt.Errorf("%v:%d: the episode %q isn't a number", path, line, columns[4])
+			}
+		}
+		if columns[5] != "" {
+			if _, err := fmt.Sscanf(columns[5], "%d-%d-%d", &c.Want.AiredYear, &c.Want.AiredMonth, &c.Want.AiredDay); err != nil {
+				return nil, fmt.Errorf("%v:%d: the air date %q isn't a date like 2024-01-05", path, line, columns[5])
+			}
+		}
+
+		if c.Name == "" || c.Want.ParseType == "" {
+			return nil, fmt.Errorf("%v:%d: each line needs a release name and a parse type", path, line)
+		}
+		cases = append(cases, c)
+	}
+
+	return cases, scanner.Err()
+}
+
+// Matches returns true if the result is what the case expects.
+// Show names are compared without regard to case
+func (c Case) Matches(got plugin.ParseInfo) bool {
+	want := c.Want
+	if got.ParseType != want.ParseType {
+		return false
+	}
+	if want.ParseType == "unknown" {
+		return true
+	}
+
+	return strings.EqualFold(got.ShowName, want.ShowName) &&
+		got.ShowYear == want.ShowYear &&
+		got.SeasonNumber == want.SeasonNumber &&
+		got.EpisodeNumber == want.EpisodeNumber &&
+		got.AiredYear == want.AiredYear &&
+		got.AiredMonth == want.AiredMonth &&
+		got.AiredDay == want.AiredDay
+}
@@ -0,0 +1,227 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// Result is what was parsed from a file name
+type Result struct {
+	plugin.ParseInfo
+
+	// Rule is the name of the parser that matched
+	Rule string
+}
+
+// File is a file name to parse
+type File struct {
+	// Path is the file's path, and Name is the file name (with its
+	// extension).  Base is the name without its extension
+	Path string
+	Name string
+	Base string
+
+	// Dirs are the directories the file is in, below the source directory
+	Dirs []string
+
+	// Tags are the tags passed with the file
+	Tags []string
+}
+
+// NewFile returns the file to parse for the given path
+func NewFile(path string, dirs, tags []string) File {
+	name := filepath.Base(path)
+	return File{Path: path, Name: name, Base: strings.TrimSuffix(name, filepath.Ext(name)), Dirs: dirs, Tags: tags}
+}
+
+// Parser reads show information from file names.  Parsers are shared
+// between goroutines, so they have to be safe for concurrent use
+type Parser interface {
+	// Name identifies the parser in results
+	Name() string
+
+	// Parse returns what the parser found in the file name, or nil if
+	// the name isn't in the parser's format
+	Parse(file File) (*Result, error)
+}
+
+// Registry is an ordered list of parsers.  It's safe for concurrent use
+type Registry struct {
+	mu      sync.RWMutex
+	parsers []Parser
+}
+
+// NewRegistry returns a registry with the given parsers
+func NewRegistry(parsers ...Parser) *Registry {
+	return &Registry{parsers: parsers}
+}
+
+// Register adds a parser to the end of the registry
+func (r *Registry) Register(p Parser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parsers = append(r.parsers, p)
+}
+
+// Parsers returns the registered parsers, in the order they're tried
+func (r *Registry) Parsers() []Parser {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Parser{}, r.parsers...)
+}
+
+// Parse parses the show information from a file name.  The first parser
+// to match wins, but every parser is tried: the others are returned as
+// alternatives (if they disagree) and change how confident the result is
+func (r *Registry) Parse(file File) (Result, error) {
+	if strings.TrimSpace(file.Name) == "" {
+		return Result{}, errors.New("Filename does not appear to be a valid filename")
+	}
+
+	var candidates []Result
+	for _, p := range r.Parsers() {
+		result, err := p.Parse(file)
+		if err != nil {
+			return Result{}, err
+		}
+		if result != nil {
+			candidates = append(candidates, *result)
+		}
+	}
+
+	result := Result{ParseInfo: plugin.ParseInfo{ParseType: "unknown"}}
+	if len(candidates) > 0 {
+		result = weigh(candidates)
+	}
+	result.Quality = Quality(file.Name)
+
+	//	Reboots and remakes have the year in the show name
+	result.ShowName, result.ShowYear = SplitYear(result.ShowName)
+	for i := range result.Alternatives {
+		result.Alternatives[i].ShowName, result.Alternatives[i].ShowYear = SplitYear(result.Alternatives[i].ShowName)
+	}
+
+	return result, nil
+}
+
+// weigh picks the first candidate, and works out how confident it is from
+// the others: candidates that agree with it make it more likely to be
+// right, and ones that don't make it less likely.  Candidates that are
+// certain (like a script's) aren't second guessed
+func weigh(candidates []Result) Result {
+	best := candidates[0]
+	confidence := best.Confidence
+	certain := confidence >= 1
+
+	//	The candidate's own alternatives (like the other way to read
+	//	a date) count too
+	others := append([]Result{}, candidates[1:]...)
+	for _, alt := range best.Alternatives {
+		others = append(others, Result{ParseInfo: alt})
+	}
+	best.Alternatives = nil
+
+	for _, other := range others {
+		if other.agrees(best) {
+			confidence = 1 - (1-confidence)*(1-other.Confidence/2)
+			continue
+		}
+
+		if !certain {
+			confidence *= 1 - other.Confidence/2
+		}
+		if !other.listed(best.Alternatives) {
+			alt := other.ParseInfo
+			alt.Confidence = math.Round(alt.Confidence*100) / 100
+			alt.Alternatives = nil
+			best.Alternatives = append(best.Alternatives, alt)
+		}
+	}
+
+	best.Confidence = math.Round(confidence*100) / 100
+	return best
+}
+
+// Describe returns a short description of a parse result, like 'Show s1e02'
+func Describe(info plugin.ParseInfo) string {
+	show := info.ShowName
+	if info.ShowYear != 0 {
+		show = fmt.Sprintf("%v (%d)", show, info.ShowYear)
+	}
+
+	switch info.ParseType {
+	case "se":
+		return fmt.Sprintf("%v s%de%02d", show, info.SeasonNumber, info.EpisodeNumber)
+	case "date":
+		return fmt.Sprintf("%v %d-%02d-%02d", show, info.AiredYear, info.AiredMonth, info.AiredDay)
+	case "extra":
+		return fmt.Sprintf("%v %v", show, info.Extra)
+	}
+	return info.ParseType
+}
+
+// agrees returns true if the two results would file the episode in the same place
+func (r Result) agrees(other Result) bool {
+	return samePlace(r.ParseInfo, other.ParseInfo)
+}
+
+// listed returns true if the result is already in the list of alternatives
+func (r Result) listed(alternatives []plugin.ParseInfo) bool {
+	for _, alt := range alternatives {
+		if samePlace(r.ParseInfo, alt) {
+			return true
+		}
+	}
+	return false
+}
+
+// samePlace returns true if the two parse results are for the same episode
+func samePlace(a, b plugin.ParseInfo) bool {
+	return a.ParseType == b.ParseType &&
+		strings.EqualFold(a.ShowName, b.ShowName) &&
+		a.SeasonNumber == b.SeasonNumber &&
+		a.EpisodeNumber == b.EpisodeNumber &&
+		a.AiredYear == b.AiredYear &&
+		a.AiredMonth == b.AiredMonth &&
+		a.AiredDay == b.AiredDay
+}
+
+// rxShowYear finds a year at the end of a show name, like 'Doctor Who 2005'
+// or 'Battlestar Galactica (2003)'
+var rxShowYear = regexp.MustCompile(`^(.*\S)[ ._-]+\(?((?:19|20)\d{2})\)?$`)
+
+// SplitYear splits the year from the end of a show name, for reboots and
+// remakes like 'Doctor Who (2005)'.  Names without one (or with a year that
+// hasn't happened yet, like 'Blade Runner 2049') are returned as they are
+func SplitYear(show string) (string, int) {
+	matches := rxShowYear.FindStringSubmatch(strings.TrimSpace(show))
+	if matches == nil {
+		return show, 0
+	}
+
+	year, _ := strconv.Atoi(matches[2])
+	if year > time.Now().Year()+1 {
+		return show, 0
+	}
+	return matches[1], year
+}
+
+// ValidDate returns true if the year, month and day make a real date
+func ValidDate(year, month, day int) bool {
+	if year < 1900 || month < 1 || month > 12 || day < 1 {
+		return false
+	}
+
+	//	time.Date rolls days past the end of the month into the next one
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	return date.Day() == day
+}
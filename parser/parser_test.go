@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+)
+
+// corpora are the corpus files, with the date order each one is for
+var corpora = []struct {
+	path      string
+	dateOrder string
+}{
+	{"testdata/releases.tsv", ""},
+	{"testdata/releases-mdy.tsv", DateOrderMDY},
+}
+
+func TestRegistryParseCorpus(t *testing.T) {
+	for _, corpus := range corpora {
+		cases, err := LoadCorpus(corpus.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cases) == 0 {
+			t.Fatalf("%v doesn't have any names in it", corpus.path)
+		}
+
+		registry := NewRegistry(Builtin(corpus.dateOrder)...)
+		for _, c := range cases {
+			c := c
+			t.Run(path.Base(corpus.path)+"/"+c.Name, func(t *testing.T) {
+				//	Extras are picked out by the mover, from the folders
+				//	they're in
+				if c.Want.ParseType == "extra" {
+					t.Skip("extras aren't parsed from the name")
+				}
+
+				//	Names can include the folders they're in
+				dirs := []string{}
+				if dir := path.Dir(c.Name); dir != "." {
+					dirs = strings.Split(dir, "/")
+				}
+
+				got, err := registry.Parse(NewFile(c.Name, dirs, nil))
+				if err != nil {
+					t.Fatalf("line %d: %v", c.Line, err)
+				}
+				if !c.Matches(got.ParseInfo) {
+					t.Errorf("line %d: parsed as %v, want %v", c.Line, Describe(got.ParseInfo), Describe(c.Want))
+				}
+			})
+		}
+	}
+}
+
+// benchmarkNames cover each of the built-in formats
+var benchmarkNames = []string{
+	"Show.Name.S%02dE%02d.720p.HDTV.x264-GROUP.mkv",
+	"Show Name %dx%02d.mkv",
+	"Show Name Season %d Episode %d.mkv",
+	"Show.Name.2024.%02d.%02d.720p.WEB.mkv",
+	"Show.Name.%02d.%02d.2023.GERMAN.720p.mkv",
+	"Show.Name.%d%02d.hdtv.mkv",
+	"Show.Name.Part.%d.%d.mkv",
+	"Home.Video.%d.%d.mkv",
+}
+
+// benchmarkFiles returns the benchmark names for a dozen seasons
+func benchmarkFiles() []File {
+	var files []File
+	for i := 1; i <= 12; i++ {
+		for _, format := range benchmarkNames {
+			files = append(files, NewFile(fmt.Sprintf(format, i, i+10), []string{}, nil))
+		}
+	}
+	return files
+}
+
+func BenchmarkRegistryParse(b *testing.B) {
+	registry := NewRegistry(Builtin("")...)
+	files := benchmarkFiles()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := registry.Parse(files[i%len(files)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRegistryParseParallel(b *testing.B) {
+	registry := NewRegistry(Builtin("")...)
+	files := benchmarkFiles()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, err := registry.Parse(files[i%len(files)]); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
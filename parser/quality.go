@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// quality is a kind of quality tag, like the resolution, with the names
+// its tags are written as
+type quality struct {
+	rx    *regexp.Regexp
+	names map[string]string
+}
+
+var (
+	//	The quality tags, in the order they're listed
+	qualities = []quality{
+		{rx: qualityTag(`480p|576p|720p|1080[pi]|2160p|4k`)},
+		{rx: qualityTag(`hdtv|pdtv|web-?dl|webrip|web|blu-?ray|bdrip|brrip|dvdrip|dvd`), names: map[string]string{
+			"hdtv": "HDTV", "pdtv": "PDTV", "webdl": "WEB-DL", "web-dl": "WEB-DL", "webrip": "WEBRip", "web": "WEB",
+			"bluray": "BluRay", "blu-ray": "BluRay", "bdrip": "BDRip", "brrip": "BRRip", "dvdrip": "DVDRip", "dvd": "DVD",
+		}},
+		{rx: qualityTag(`x264|x265|h\.?264|h\.?265|hevc|xvid|av1`), names: map[string]string{
+			"h264": "H.264", "h.264": "H.264", "h265": "H.265", "h.265": "H.265", "hevc": "HEVC", "xvid": "XviD", "av1": "AV1",
+		}},
+	}
+)
+
+// qualityTag compiles the pattern for a kind of quality tag.  Tags have
+// to be separated from the rest of the name
+func qualityTag(tags string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(?:^|[. _\[(-])(` + tags + `)(?:[. _\])-]|$)`)
+}
+
+// Quality returns the quality tags in a file name (the resolution, source
+// and codec), like '1080p WEB-DL x264'.  It's empty if there aren't any
+func Quality(name string) string {
+	var tags []string
+	for _, q := range qualities {
+		matches := q.rx.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+
+		tag := strings.ToLower(matches[1])
+		if named, ok := q.names[tag]; ok {
+			tag = named
+		}
+		tags = append(tags, tag)
+	}
+	return strings.Join(tags, " ")
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// CustomConfidence is how confident matches from custom rules are
+const CustomConfidence = 0.9
+
+// Groups are the named groups a rule's pattern can use
+var Groups = []string{"show", "season", "episode", "year", "month", "monthname", "day", "title"}
+
+// Finds a season at the end of a show name
+var rxTrailingSeason = regexp.MustCompile(`(?i)\b(?:season|series|s) ?\d{1,2}$`)
+
+// Rule is a single file name format.  The pattern is matched against the
+// file name without its extension, and uses named groups: show, season and
+// episode, or year, month (or monthname) and day.  Episode rules without a
+// season group are for shows with a single season.  A title group, if there
+// is one, is the episode title
+type Rule struct {
+	name string
+	rx   *regexp.Regexp
+
+	// confidence is how likely a match is to be right, from 0 to 1
+	confidence float64
+
+	// ambiguous rules have day and month groups that could be either
+	// way around, depending on the date order
+	ambiguous bool
+	dateOrder string
+}
+
+// NewRule compiles a rule, making sure its pattern has the groups it needs
+func NewRule(name, pattern string) (Rule, error) {
+	rx, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	groups := make(map[string]bool)
+	for _, group := range rx.SubexpNames() {
+		if group == "" {
+			continue
+		}
+		if !containsString(Groups, group) {
+			return Rule{}, fmt.Errorf("unknown group %q (use %s)", group, strings.Join(Groups, ", "))
+		}
+		groups[group] = true
+	}
+
+	switch {
+	case !groups["show"]:
+		return Rule{}, fmt.Errorf("the pattern needs a show group")
+	case groups["year"]:
+		if !groups["day"] || !(groups["month"] || groups["monthname"]) {
+			return Rule{}, fmt.Errorf("a pattern with a year group needs month and day groups too")
+		}
+	case !groups["episode"]:
+		return Rule{}, fmt.Errorf("the pattern needs an episode group, or year, month and day groups")
+	}
+
+	return Rule{name: name, rx: rx, confidence: CustomConfidence}, nil
+}
+
+// Name returns the rule's name
+func (r Rule) Name() string {
+	return r.name
+}
+
+// Parse matches the rule against the file name (without its extension)
+func (r Rule) Parse(file File) (*Result, error) {
+	if result, ok := r.match(file.Base); ok {
+		return &result, nil
+	}
+	return nil, nil
+}
+
+// date returns true if the rule is for air dates
+func (r Rule) date() bool {
+	for _, group := range r.rx.SubexpNames() {
+		if group == "year" {
+			return true
+		}
+	}
+	return false
+}
+
+// match tries the rule against the file name (without its extension)
+func (r Rule) match(name string) (Result, bool) {
+	matches := r.rx.FindStringSubmatch(name)
+	if matches == nil {
+		return Result{}, false
+	}
+
+	groups := make(map[string]string)
+	for i, group := range r.rx.SubexpNames() {
+		if group != "" {
+			groups[group] = matches[i]
+		}
+	}
+
+	show := cleanShow(groups["show"])
+	if show == "" {
+		return Result{}, false
+	}
+
+	var result Result
+	var ok bool
+	if year, isDate := groups["year"]; isDate {
+		result, ok = r.matchDate(show, year, groups)
+	} else {
+		result, ok = r.matchEpisode(show, groups)
+	}
+	if ok {
+		result.Title = cleanShow(groups["title"])
+	}
+
+	return result, ok
+}
+
+// matchEpisode builds the result for a season and episode rule
+func (r Rule) matchEpisode(show string, groups map[string]string) (Result, bool) {
+	result := Result{Rule: r.name, ParseInfo: plugin.ParseInfo{ParseType: "se", ShowName: show, SeasonNumber: 1, Confidence: r.confidence}}
+
+	var err error
+	if season, ok := groups["season"]; ok {
+		if result.SeasonNumber, err = strconv.Atoi(season); err != nil {
+			return Result{}, false
+		}
+	} else if rxTrailingSeason.MatchString(show) {
+		//	'Show Season 2 Episode 5' is season 2, not a show
+		//	called 'Show Season 2'
+		return Result{}, false
+	}
+	if result.EpisodeNumber, err = strconv.Atoi(groups["episode"]); err != nil {
+		return Result{}, false
+	}
+
+	return result, true
+}
+
+// matchDate builds the result for a date rule.  Dates that aren't real
+// (like 2024-13-45) don't match
+func (r Rule) matchDate(show, year string, groups map[string]string) (Result, bool) {
+	result := Result{Rule: r.name, ParseInfo: plugin.ParseInfo{ParseType: "date", ShowName: show, Confidence: r.confidence}}
+	result.AiredYear, _ = strconv.Atoi(year)
+	result.AiredDay, _ = strconv.Atoi(groups["day"])
+	if name, ok := groups["monthname"]; ok {
+		result.AiredMonth = monthNumber(name)
+	} else if month, err := strconv.Atoi(groups["month"]); err == nil {
+		result.AiredMonth = month
+	} else {
+		result.AiredMonth = monthNumber(groups["month"])
+	}
+
+	if r.ambiguous {
+		//	Read the date the preferred way around, unless it only
+		//	makes sense the other way
+		day, month := result.AiredDay, result.AiredMonth
+		if r.dateOrder == DateOrderMDY {
+			day, month = month, day
+		}
+		if !ValidDate(result.AiredYear, month, day) {
+			day, month = month, day
+		}
+		result.AiredDay, result.AiredMonth = day, month
+
+		//	If it makes sense the other way around too, that's
+		//	an alternative
+		if day != month && ValidDate(result.AiredYear, day, month) {
+			other := result.ParseInfo
+			other.AiredDay, other.AiredMonth = month, day
+			other.Confidence = r.confidence / 2
+			result.Alternatives = append(result.Alternatives, other)
+		}
+	}
+
+	if !ValidDate(result.AiredYear, result.AiredMonth, result.AiredDay) {
+		return Result{}, false
+	}
+
+	return result, true
+}
+
+// monthNumber returns the number of the month with the given (long or short) name
+func monthNumber(name string) int {
+	if len(name) < 3 {
+		return 0
+	}
+
+	prefix := strings.ToLower(name)[:3]
+	for month := time.January; month <= time.December; month++ {
+		if strings.ToLower(month.String())[:3] == prefix {
+			return int(month)
+		}
+	}
+	return 0
+}
+
+// containsString returns true if the list contains the string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actions is the list of built-in actions
+var Actions = []string{"delete-source", "http", "symlink", "write-file", "chmod"}
+
+// Builtin holds the settings for a built-in action.  Built-in actions run
+// inside plexbot, so they don't need any extra executables on the host.
+// Each of the string settings can use tokens
+type Builtin struct {
+	// Action is the name of the built-in action to run
+	Action string `yaml:"action,omitempty" json:"action,omitempty" toml:"action,omitempty" mapstructure:"action"`
+
+	// Path is the file the action works on.  For symlink, it's where the link
+	// gets created.  For delete-source, it defaults to {oldfilepath}, and for
+	// chmod it defaults to {newfilepath}
+	Path string `yaml:"path,omitempty" json:"path,omitempty" toml:"path,omitempty" mapstructure:"path"`
+
+	// Target is what a symlink points to.  It defaults to {newfilepath}
+	Target string `yaml:"target,omitempty" json:"target,omitempty" toml:"target,omitempty" mapstructure:"target"`
+
+	// Content is what write-file writes
+	Content string `yaml:"content,omitempty" json:"content,omitempty" toml:"content,omitempty" mapstructure:"content"`
+
+	// Mode is the octal file mode for chmod (like 0664)
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty" toml:"mode,omitempty" mapstructure:"mode"`
+
+	// URL, Method, Headers and Body are the request settings for http
+	URL     string            `yaml:"url,omitempty" json:"url,omitempty" toml:"url,omitempty" mapstructure:"url"`
+	Method  string            `yaml:"method,omitempty" json:"method,omitempty" toml:"method,omitempty" mapstructure:"method"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" toml:"headers,omitempty" mapstructure:"headers"`
+	Body    string            `yaml:"body,omitempty" json:"body,omitempty" toml:"body,omitempty" mapstructure:"body"`
+}
+
+// String describes the action for logs and reports
+func (b Builtin) String() string {
+	switch b.Action {
+	case "http":
+		return fmt.Sprintf("action: http %s %s", b.method(), b.URL)
+	case "symlink":
+		return fmt.Sprintf("action: symlink %s -> %s", b.Path, b.Target)
+	case "chmod":
+		return fmt.Sprintf("action: chmod %s %s", b.Mode, b.Path)
+	}
+	return strings.TrimSpace(fmt.Sprintf("action: %s %s", b.Action, b.Path))
+}
+
+// Format returns a copy of the action with the tokens replaced in each of its
+// settings, and any defaults filled in
+func (b Builtin) Format(tokens map[string]string) Builtin {
+	switch b.Action {
+	case "delete-source":
+		if b.Path == "" {
+			b.Path = "{oldfilepath}"
+		}
+	case "chmod":
+		if b.Path == "" {
+			b.Path = "{newfilepath}"
+		}
+	case "symlink":
+		if b.Target == "" {
+			b.Target = "{newfilepath}"
+		}
+	}
+
+	formatted := b
+	formatted.Path = FormatTokenizedString(b.Path, tokens)
+	formatted.Target = FormatTokenizedString(b.Target, tokens)
+	formatted.Content = FormatTokenizedString(b.Content, tokens)
+	formatted.URL = FormatTokenizedString(b.URL, urlTokens(tokens))
+	formatted.Body = FormatTokenizedString(b.Body, tokens)
+
+	if len(b.Headers) > 0 {
+		formatted.Headers = make(map[string]string)
+		for name, value := range b.Headers {
+			formatted.Headers[name] = FormatTokenizedString(value, tokens)
+		}
+	}
+
+	return formatted
+}
+
+// urlTokens returns a copy of the tokens with their values escaped
+// so they can be used anywhere in a URL
+func urlTokens(tokens map[string]string) map[string]string {
+	escaped := make(map[string]string, len(tokens))
+	for token, value := range tokens {
+		escaped[token] = strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+	}
+	return escaped
+}
+
+// RunBuiltin runs a built-in action (which should already have its tokens
+// replaced).  It returns a description of what happened, and any error
+func RunBuiltin(b Builtin) (string, error) {
+	switch b.Action {
+	case "delete-source":
+		if err := os.Remove(b.Path); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Deleted %s", b.Path), nil
+
+	case "symlink":
+		if err := os.MkdirAll(filepath.Dir(b.Path), os.ModePerm); err != nil {
+			return "", err
+		}
+
+		//	Replace an existing link, but nothing else
+		if info, err := os.Lstat(b.Path); err == nil {
+			if info.Mode()&os.ModeSymlink == 0 {
+				return "", fmt.Errorf("%s already exists and isn't a symlink", b.Path)
+			}
+			os.Remove(b.Path)
+		}
+
+		if err := os.Symlink(b.Target, b.Path); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Linked %s to %s", b.Path, b.Target), nil
+
+	case "write-file":
+		if err := os.MkdirAll(filepath.Dir(b.Path), os.ModePerm); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(b.Path, []byte(b.Content), 0644); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Wrote %s", b.Path), nil
+
+	case "chmod":
+		mode, err := ParseMode(b.Mode)
+		if err != nil {
+			return "", err
+		}
+		if err := os.Chmod(b.Path, mode); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Changed the mode of %s to %s", b.Path, b.Mode), nil
+
+	case "http":
+		return b.request()
+	}
+
+	return "", fmt.Errorf("unknown action %q", b.Action)
+}
+
+// ParseMode parses an octal file mode like 0664
+func ParseMode(mode string) (os.FileMode, error) {
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || parsed > 0777 {
+		return 0, fmt.Errorf("%q isn't a file mode (try something like 0664)", mode)
+	}
+	return os.FileMode(parsed), nil
+}
+
+// request makes the http request for the action
+func (b Builtin) request() (string, error) {
+	var body io.Reader
+	if b.Body != "" {
+		body = strings.NewReader(b.Body)
+	}
+
+	req, err := http.NewRequest(b.method(), b.URL, body)
+	if err != nil {
+		return "", err
+	}
+
+	for name, value := range b.Headers {
+		req.Header.Set(name, value)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	//	Keep a little bit of the response for the report
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	output := strings.TrimSpace(fmt.Sprintf("%s %s", resp.Status, response))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return output, fmt.Errorf("%s %s returned %s", b.method(), b.URL, resp.Status)
+	}
+
+	return output, nil
+}
+
+// method returns the http method, which defaults to GET (or POST, if
+// there's a body)
+func (b Builtin) method() string {
+	if b.Method != "" {
+		return strings.ToUpper(b.Method)
+	}
+	if b.Body != "" {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
@@ -0,0 +1,71 @@
+package plugin
+
+// Event describes what plexbot is doing when it runs a plugin.  Plugins
+// that ask for it get the event on stdin as JSON
+type Event struct {
+	Stage  string            `json:"stage"`
+	Run    RunInfo           `json:"run"`
+	File   *FileInfo         `json:"file,omitempty"`
+	Tokens map[string]string `json:"tokens"`
+
+	//	Only filled in for the premove section
+	Plan *Plan `json:"plan,omitempty"`
+}
+
+// RunInfo describes the run
+type RunInfo struct {
+	ID        string   `json:"id"`
+	Source    string   `json:"source"`
+	Hash      string   `json:"hash,omitempty"`
+	Tags      []string `json:"tags"`
+	TVPath    string   `json:"tvpath"`
+	ErrorPath string   `json:"errorpath"`
+
+	//	Only filled in for the postprocessall section
+	Totals *RunTotals `json:"totals,omitempty"`
+}
+
+// RunTotals describes the results of the whole run
+type RunTotals struct {
+	MovedFiles    []string `json:"movedfiles"`
+	ShowFolders   []string `json:"showfolders"`
+	SeasonFolders []string `json:"seasonfolders"`
+	Moved         int      `json:"moved"`
+	Failed        int      `json:"failed"`
+	Skipped       int      `json:"skipped"`
+}
+
+// FileInfo describes the file being processed
+type FileInfo struct {
+	Path        string     `json:"path"`
+	Name        string     `json:"name"`
+	Size        int64      `json:"size"`
+	Destination string     `json:"destination,omitempty"`
+	Outcome     string     `json:"outcome,omitempty"`
+	Parse       *ParseInfo `json:"parse,omitempty"`
+}
+
+// ParseInfo describes what plexbot found when parsing the filename
+type ParseInfo struct {
+	ParseType     string `json:"parsetype"`
+	ShowName      string `json:"showname"`
+	ShowYear      int    `json:"showyear,omitempty"`
+	SeasonNumber  int    `json:"season,omitempty"`
+	EpisodeNumber int    `json:"episode,omitempty"`
+	AiredYear     int    `json:"airedyear,omitempty"`
+	AiredMonth    int    `json:"airedmonth,omitempty"`
+	AiredDay      int    `json:"airedday,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Quality       string `json:"quality,omitempty"`
+
+	// Extra is the Plex extras folder for extras (like Featurettes)
+	Extra string `json:"extra,omitempty"`
+
+	// Parser is the name of the parser (or parse rule) that matched
+	Parser string `json:"parser,omitempty"`
+
+	// Confidence is how likely the parse is to be right, from 0 to 1.
+	// Alternatives are the other ways the name could be read
+	Confidence   float64     `json:"confidence,omitempty"`
+	Alternatives []ParseInfo `json:"alternatives,omitempty"`
+}
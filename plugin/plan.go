@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Plan is the proposed move for a single file.  Premove hooks get it on
+// stdin (as part of the event) and can reply with a PlanReply to change it
+type Plan struct {
+	ParseInfo
+
+	// Destination is the full path the file will be copied to
+	Destination string `json:"destination"`
+
+	// Library is where the file is going: tv, movies, errors or ignored
+	// (for shows that are ignored in the config)
+	Library string `json:"library"`
+
+	// Reason says why a file that was parsed is going to the errors path,
+	// or is ignored
+	Reason string `json:"reason,omitempty"`
+}
+
+// PlanReply is what a premove hook writes to stdout to change the plan.
+// Fields that aren't set are left alone.  An empty reply accepts the plan
+type PlanReply struct {
+	ShowName      *string `json:"showname"`
+	ShowYear      *int    `json:"showyear"`
+	SeasonNumber  *int    `json:"season"`
+	EpisodeNumber *int    `json:"episode"`
+	AiredYear     *int    `json:"airedyear"`
+	AiredMonth    *int    `json:"airedmonth"`
+	AiredDay      *int    `json:"airedday"`
+
+	// Destination replaces the destination path.  Relative paths are
+	// relative to the Plex TV library path
+	Destination *string `json:"destination"`
+
+	// Skip leaves the file where it is
+	Skip bool `json:"skip"`
+
+	// Abort stops the run.  Files that haven't been processed yet are skipped
+	Abort bool `json:"abort"`
+
+	// Reason explains a skip or abort
+	Reason string `json:"reason"`
+}
+
+// ParseReply reads a premove hook's reply from its output
+func ParseReply(output string) (PlanReply, error) {
+	reply := PlanReply{}
+
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return reply, nil
+	}
+
+	if err := json.Unmarshal([]byte(output), &reply); err != nil {
+		return reply, fmt.Errorf("the premove hook replied with something other than JSON: %v", err)
+	}
+
+	return reply, nil
+}
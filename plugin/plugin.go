@@ -2,13 +2,66 @@ package plugin
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"log"
+	"os"
 	"os/exec"
+	"sort"
 	"strings"
 )
 
-// ExecutePlugin takes a plugin command and executes it
-func ExecutePlugin(pluginCommand string) {
+// CommonTokens are the tokens that can be used in any plugin command
+var CommonTokens = []string{
+	"{tvpath}",
+	"{errorpath}",
+	"{hash}",
+	"{runid}",
+}
+
+// FileTokens are the tokens that describe a single file.  They can be
+// used in the preprocess and postprocess sections
+var FileTokens = []string{
+	"{oldfilepath}",
+	"{newfilepath}",
+	"{showname}",
+	"{showseasonnumber}",
+	"{showepisodenumber}",
+}
+
+// RunTokens are the tokens that describe the whole run.  They can
+// only be used in the postprocessall section
+var RunTokens = []string{
+	"{movedfiles}",
+	"{showfolders}",
+	"{seasonfolders}",
+	"{movedcount}",
+	"{failedcount}",
+	"{skippedcount}",
+}
+
+// TokensFor returns the tokens that can be used in the given plugin section
+func TokensFor(section string) []string {
+	tokens := append([]string{}, CommonTokens...)
+	if section == "postprocessall" {
+		return append(tokens, RunTokens...)
+	}
+	return append(tokens, FileTokens...)
+}
+
+// ExecutePlugin takes a plugin command and executes it.  Each of the tokens
+// is exported to the plugin as an environment variable, and if an event is
+// passed it's written to the plugin's stdin as JSON.  It returns the
+// combined output of the command and any error encountered running it
+func ExecutePlugin(pluginCommand string, tokens map[string]string, event *Event) (string, error) {
+	stdout, stderr, err := ExecuteHook(pluginCommand, tokens, event)
+	return strings.TrimSpace(stdout + stderr), err
+}
+
+// ExecuteHook executes a plugin command like ExecutePlugin, but returns
+// stdout and stderr separately.  Premove hooks reply on stdout, so they
+// can log to stderr
+func ExecuteHook(pluginCommand string, tokens map[string]string, event *Event) (string, string, error) {
 	//	Split the entire command up using ' -' as the delimeter
 	parts := strings.Split(pluginCommand, " -")
 
@@ -18,6 +71,16 @@ func ExecutePlugin(pluginCommand string) {
 
 	//	Format the command
 	cmd := exec.Command(head, args...)
+	cmd.Env = append(os.Environ(), TokenEnvironment(tokens)...)
+
+	//	If we have an event, pass it on stdin
+	if event != nil {
+		input, err := json.Marshal(event)
+		if err != nil {
+			return "", "", fmt.Errorf("problem formatting the plugin event: %v", err)
+		}
+		cmd.Stdin = bytes.NewReader(input)
+	}
 
 	/*
 		//	Sanity check -- just print out the detected args:
@@ -33,10 +96,23 @@ func ExecutePlugin(pluginCommand string) {
 	cmd.Stderr = &stderr
 
 	//	Run the command
-	cmd.Run()
+	err := cmd.Run()
+
+	log.Printf("[DEBUG] Hook output: %q / %q", out.String(), stderr.String())
 
-	//	Output our results
-	fmt.Printf("Result: %v / %v", out.String(), stderr.String())
+	return out.String(), stderr.String(), err
+}
+
+// TokenEnvironment formats each of the tokens as an environment variable.
+// For example, {showname} becomes PLEXBOT_SHOWNAME
+func TokenEnvironment(tokens map[string]string) []string {
+	var env []string
+	for token, value := range tokens {
+		name := "PLEXBOT_" + strings.ToUpper(strings.Trim(token, "{}"))
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
 }
 
 // FormatTokenizedString will format a string containing tokens by replacing
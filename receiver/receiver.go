@@ -0,0 +1,310 @@
+package receiver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danesparza/plexbot/plugin"
+	"github.com/danesparza/plexbot/report"
+)
+
+// Import is a file another tool wants plexbot to import, along with
+// what it already knows about the show
+type Import struct {
+	// Source is the tool that sent the import, like sonarr or radarr
+	Source string
+
+	// Path is the file to import
+	Path string
+
+	// Hash is the download ID, if the tool knows it
+	Hash string
+
+	// Metadata describes the show and episode (or the movie), so the
+	// file name doesn't have to be parsed
+	Metadata plugin.ParseInfo
+}
+
+// ImportFunc moves a single file into the library
+type ImportFunc func(Import) (*report.Report, error)
+
+// Handler receives Sonarr "on import" webhooks at /sonarr (and Radarr ones
+// at /radarr), and passes each import on.  Imports are run one at a time
+type Handler struct {
+	// Username and Password turn on basic authentication, if they're set
+	Username string
+	Password string
+
+	// Roots are the download directories files can be imported from
+	Roots []string
+
+	// Import is called for each file
+	Import ImportFunc
+
+	mu sync.Mutex
+}
+
+// response is what the handler replies with
+type response struct {
+	Status string         `json:"status"`
+	Error  string         `json:"error,omitempty"`
+	Report *report.Report `json:"report,omitempty"`
+}
+
+// maxBody is the biggest webhook payload we'll read
+const maxBody = 1 << 20
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		reply(w, http.StatusMethodNotAllowed, response{Status: "error", Error: "Webhooks have to be sent with POST"})
+		return
+	}
+
+	if !h.authorized(req) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="plexbot"`)
+		reply(w, http.StatusUnauthorized, response{Status: "error", Error: "Unauthorized"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBody))
+	if err != nil {
+		reply(w, http.StatusBadRequest, response{Status: "error", Error: err.Error()})
+		return
+	}
+
+	var imp *Import
+	switch strings.TrimSuffix(req.URL.Path, "/") {
+	case "/sonarr":
+		imp, err = ParseSonarr(body)
+	case "/radarr":
+		imp, err = ParseRadarr(body)
+	default:
+		reply(w, http.StatusNotFound, response{Status: "error", Error: "Send Sonarr webhooks to /sonarr and Radarr webhooks to /radarr"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Problem with the webhook sent to %v: %v", req.URL.Path, err)
+		reply(w, http.StatusBadRequest, response{Status: "error", Error: err.Error()})
+		return
+	}
+
+	//	Tests and other events don't import anything
+	if imp == nil {
+		reply(w, http.StatusOK, response{Status: "ignored"})
+		return
+	}
+
+	//	Only import files from the download directories, so a webhook
+	//	can't copy (or delete) anything else plexbot can read
+	if !h.allowed(imp.Path) {
+		log.Printf("[ERROR] Refusing to import %v: it isn't in one of the download directories", imp.Path)
+		reply(w, http.StatusForbidden, response{Status: "error", Error: "The file isn't in one of the download directories plexbot imports from"})
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	log.Printf("[INFO] Importing %v from %v", imp.Path, imp.Source)
+	runReport, err := h.Import(*imp)
+
+	switch {
+	case err != nil:
+		reply(w, http.StatusInternalServerError, response{Status: "error", Error: err.Error(), Report: runReport})
+	case runReport.HasFailures():
+		reply(w, http.StatusInternalServerError, response{Status: "failed", Report: runReport})
+	default:
+		reply(w, http.StatusOK, response{Status: "imported", Report: runReport})
+	}
+}
+
+// authorized checks the basic authentication credentials, if we need them
+func (h *Handler) authorized(req *http.Request) bool {
+	if h.Username == "" && h.Password == "" {
+		return true
+	}
+
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	userMatch := subtle.ConstantTimeCompare([]byte(username), []byte(h.Username)) == 1
+	passMatch := subtle.ConstantTimeCompare([]byte(password), []byte(h.Password)) == 1
+	return userMatch && passMatch
+}
+
+// allowed returns true if the file is in (or below) one of the download
+// directories.  Symlinks are followed, so they can't point outside them
+func (h *Handler) allowed(path string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+
+	file := resolve(path)
+	for _, root := range h.Roots {
+		rel, err := filepath.Rel(resolve(root), file)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve cleans the path and follows its symlinks.  If the path doesn't
+// exist, the part of it that does is resolved
+func resolve(path string) string {
+	path = filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+
+	//	A missing file can still be below a symlink to somewhere else
+	dir, file := filepath.Split(path)
+	if dir = filepath.Clean(dir); dir == path {
+		return path
+	}
+	return filepath.Join(resolve(dir), file)
+}
+
+// reply writes the response as JSON
+func reply(w http.ResponseWriter, status int, r response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(r)
+}
+
+// sonarrPayload is the part of a Sonarr webhook we use
+type sonarrPayload struct {
+	EventType string `json:"eventType"`
+	Series    struct {
+		Title string `json:"title"`
+		Type  string `json:"type"`
+	} `json:"series"`
+	Episodes []struct {
+		SeasonNumber  int    `json:"seasonNumber"`
+		EpisodeNumber int    `json:"episodeNumber"`
+		AirDate       string `json:"airDate"`
+	} `json:"episodes"`
+	EpisodeFile struct {
+		Path       string `json:"path"`
+		SourcePath string `json:"sourcePath"`
+	} `json:"episodeFile"`
+	DownloadID string `json:"downloadId"`
+}
+
+// ParseSonarr reads a Sonarr webhook.  It returns nil (and no error)
+// for events that don't import anything, like tests
+func ParseSonarr(body []byte) (*Import, error) {
+	var payload sonarrPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("the webhook isn't valid JSON: %v", err)
+	}
+
+	if !strings.EqualFold(payload.EventType, "Download") {
+		return nil, nil
+	}
+
+	imp := &Import{Source: "sonarr", Path: payload.EpisodeFile.SourcePath, Hash: payload.DownloadID}
+	if imp.Path == "" {
+		imp.Path = payload.EpisodeFile.Path
+	}
+
+	switch {
+	case imp.Path == "":
+		return nil, fmt.Errorf("the webhook doesn't have an episodeFile path")
+	case payload.Series.Title == "":
+		return nil, fmt.Errorf("the webhook doesn't have a series title")
+	case len(payload.Episodes) == 0:
+		return nil, fmt.Errorf("the webhook doesn't have any episodes")
+	}
+
+	//	Multi-episode files are filed under their first episode
+	episode := payload.Episodes[0]
+	imp.Metadata = plugin.ParseInfo{
+		ParseType:     "se",
+		ShowName:      payload.Series.Title,
+		SeasonNumber:  episode.SeasonNumber,
+		EpisodeNumber: episode.EpisodeNumber,
+	}
+
+	//	Daily shows are filed by their air date
+	if strings.EqualFold(payload.Series.Type, "daily") && episode.AirDate != "" {
+		aired, err := time.Parse("2006-01-02", episode.AirDate)
+		if err != nil {
+			return nil, fmt.Errorf("the episode air date %q isn't a date: %v", episode.AirDate, err)
+		}
+		imp.Metadata = plugin.ParseInfo{
+			ParseType:  "date",
+			ShowName:   payload.Series.Title,
+			AiredYear:  aired.Year(),
+			AiredMonth: int(aired.Month()),
+			AiredDay:   aired.Day(),
+		}
+	}
+
+	return imp, nil
+}
+
+// radarrPayload is the part of a Radarr webhook we use
+type radarrPayload struct {
+	EventType string `json:"eventType"`
+	Movie     struct {
+		Title      string `json:"title"`
+		Year       int    `json:"year"`
+		FolderPath string `json:"folderPath"`
+	} `json:"movie"`
+	MovieFile struct {
+		Path         string `json:"path"`
+		RelativePath string `json:"relativePath"`
+		SourcePath   string `json:"sourcePath"`
+	} `json:"movieFile"`
+	DownloadID string `json:"downloadId"`
+}
+
+// ParseRadarr reads a Radarr webhook.  It returns nil (and no error)
+// for events that don't import anything, like tests
+func ParseRadarr(body []byte) (*Import, error) {
+	var payload radarrPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("the webhook isn't valid JSON: %v", err)
+	}
+
+	if !strings.EqualFold(payload.EventType, "Download") {
+		return nil, nil
+	}
+
+	//	The relative path is relative to the movie's folder
+	imp := &Import{Source: "radarr", Path: payload.MovieFile.SourcePath, Hash: payload.DownloadID}
+	switch {
+	case imp.Path != "":
+	case payload.MovieFile.Path != "":
+		imp.Path = payload.MovieFile.Path
+	case payload.MovieFile.RelativePath != "" && payload.Movie.FolderPath != "":
+		imp.Path = filepath.Join(payload.Movie.FolderPath, payload.MovieFile.RelativePath)
+	}
+
+	switch {
+	case imp.Path == "":
+		return nil, fmt.Errorf("the webhook doesn't have a movieFile path")
+	case payload.Movie.Title == "":
+		return nil, fmt.Errorf("the webhook doesn't have a movie title")
+	}
+
+	imp.Metadata = plugin.ParseInfo{
+		ParseType: "movie",
+		ShowName:  payload.Movie.Title,
+		ShowYear:  payload.Movie.Year,
+	}
+
+	return imp, nil
+}
@@ -0,0 +1,236 @@
+package receiver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danesparza/plexbot/plugin"
+	"github.com/danesparza/plexbot/report"
+)
+
+// testHandler returns a handler for a download directory, along with the
+// imports it was asked to run
+func testHandler(t *testing.T) (*Handler, string, *[]Import) {
+	t.Helper()
+
+	root := t.TempDir()
+	var imports []Import
+	handler := &Handler{
+		Username: "plexbot",
+		Password: "secret",
+		Roots:    []string{root},
+		Import: func(imp Import) (*report.Report, error) {
+			imports = append(imports, imp)
+			return &report.Report{}, nil
+		},
+	}
+	return handler, root, &imports
+}
+
+// send posts the body to the handler, with the credentials if they're set
+func send(t *testing.T, h *Handler, path, username, password, body string) (int, response) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var r response
+	if err := json.NewDecoder(rec.Body).Decode(&r); err != nil {
+		t.Fatalf("The reply isn't JSON: %v", err)
+	}
+	return rec.Code, r
+}
+
+// sonarrBody returns a Sonarr import webhook for the file
+func sonarrBody(path string) string {
+	body, _ := json.Marshal(map[string]interface{}{
+		"eventType":   "Download",
+		"series":      map[string]interface{}{"title": "Show Name", "type": "standard"},
+		"episodes":    []map[string]interface{}{{"seasonNumber": 1, "episodeNumber": 2}},
+		"episodeFile": map[string]interface{}{"path": "/tv/Show Name/s01e02.mkv", "sourcePath": path},
+		"downloadId":  "ABC123",
+	})
+	return string(body)
+}
+
+// radarrBody returns a Radarr import webhook for the movie file
+func radarrBody(movie, movieFile map[string]interface{}) string {
+	body, _ := json.Marshal(map[string]interface{}{
+		"eventType":  "Download",
+		"movie":      movie,
+		"movieFile":  movieFile,
+		"downloadId": "DEF456",
+	})
+	return string(body)
+}
+
+func TestAuthentication(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "plexbot", "wrong", http.StatusUnauthorized},
+		{"wrong username", "someone", "secret", http.StatusUnauthorized},
+		{"right credentials", "plexbot", "secret", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, root, imports := testHandler(t)
+			status, r := send(t, h, "/sonarr", test.username, test.password, sonarrBody(filepath.Join(root, "Show.Name.S01E02.mkv")))
+			if status != test.want {
+				t.Errorf("Status = %d (%+v), want %d", status, r, test.want)
+			}
+			if test.want != http.StatusOK && len(*imports) != 0 {
+				t.Errorf("Imported %+v without the right credentials", *imports)
+			}
+		})
+	}
+
+	//	Without a username and password, anyone can send webhooks
+	h, root, _ := testHandler(t)
+	h.Username, h.Password = "", ""
+	if status, r := send(t, h, "/sonarr", "", "", sonarrBody(filepath.Join(root, "Show.Name.S01E02.mkv"))); status != http.StatusOK {
+		t.Errorf("Status without authentication = %d (%+v), want %d", status, r, http.StatusOK)
+	}
+}
+
+func TestOutsideRoots(t *testing.T) {
+	h, root, imports := testHandler(t)
+	outside := t.TempDir()
+
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"another directory", filepath.Join(outside, "Show.Name.S01E02.mkv")},
+		{"dot dot", filepath.Join(root, "..", filepath.Base(outside), "Show.Name.S01E02.mkv")},
+		{"relative path", "Show.Name.S01E02.mkv"},
+		{"the root itself", root},
+		{"symlink out of the root", filepath.Join(link, "Show.Name.S01E02.mkv")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, body := range []string{
+				sonarrBody(test.path),
+				radarrBody(map[string]interface{}{"title": "Some Movie", "year": 2005}, map[string]interface{}{"sourcePath": test.path}),
+			} {
+				path := "/sonarr"
+				if strings.Contains(body, "movieFile") {
+					path = "/radarr"
+				}
+				if status, r := send(t, h, path, "plexbot", "secret", body); status != http.StatusForbidden {
+					t.Errorf("%v status = %d (%+v), want %d", path, status, r, http.StatusForbidden)
+				}
+			}
+		})
+	}
+
+	if len(*imports) != 0 {
+		t.Errorf("Imported %+v from outside the download directories", *imports)
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	h, root, imports := testHandler(t)
+	file := filepath.Join(root, "Some.File.mkv")
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want *Import
+	}{
+		{
+			"sonarr episode", "/sonarr", sonarrBody(file),
+			&Import{Source: "sonarr", Path: file, Hash: "ABC123", Metadata: plugin.ParseInfo{ParseType: "se", ShowName: "Show Name", SeasonNumber: 1, EpisodeNumber: 2}},
+		},
+		{
+			"sonarr daily show", "/sonarr/",
+			`{"eventType": "Download", "series": {"title": "The Daily Show", "type": "daily"}, "episodes": [{"seasonNumber": 2024, "episodeNumber": 30, "airDate": "2024-03-05"}], "episodeFile": {"path": "` + file + `"}}`,
+			&Import{Source: "sonarr", Path: file, Metadata: plugin.ParseInfo{ParseType: "date", ShowName: "The Daily Show", AiredYear: 2024, AiredMonth: 3, AiredDay: 5}},
+		},
+		{
+			"radarr source path", "/radarr",
+			radarrBody(map[string]interface{}{"title": "Some Movie", "year": 2005, "folderPath": "/movies/Some Movie (2005)"}, map[string]interface{}{"relativePath": "Some Movie (2005).mkv", "sourcePath": file}),
+			&Import{Source: "radarr", Path: file, Hash: "DEF456", Metadata: plugin.ParseInfo{ParseType: "movie", ShowName: "Some Movie", ShowYear: 2005}},
+		},
+		{
+			"radarr relative path", "/radarr",
+			radarrBody(map[string]interface{}{"title": "Some Movie", "year": 2005, "folderPath": root}, map[string]interface{}{"relativePath": "Some.File.mkv"}),
+			&Import{Source: "radarr", Path: file, Hash: "DEF456", Metadata: plugin.ParseInfo{ParseType: "movie", ShowName: "Some Movie", ShowYear: 2005}},
+		},
+		{"sonarr test", "/sonarr", `{"eventType": "Test"}`, nil},
+		{"radarr test", "/radarr", `{"eventType": "Test"}`, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			*imports = nil
+			status, r := send(t, h, test.path, "plexbot", "secret", test.body)
+			if status != http.StatusOK {
+				t.Fatalf("Status = %d (%+v), want %d", status, r, http.StatusOK)
+			}
+
+			switch {
+			case test.want == nil && (r.Status != "ignored" || len(*imports) != 0):
+				t.Errorf("Reply = %+v, imports = %+v, want the event ignored", r, *imports)
+			case test.want != nil && (len(*imports) != 1 || !reflect.DeepEqual((*imports)[0], *test.want)):
+				t.Errorf("Imports = %+v, want %+v", *imports, *test.want)
+			}
+		})
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	h, root, _ := testHandler(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{"unknown path", "/lidarr", sonarrBody(filepath.Join(root, "a.mkv")), http.StatusNotFound, "/radarr"},
+		{"not JSON", "/sonarr", "{", http.StatusBadRequest, "isn't valid JSON"},
+		{"no episodes", "/sonarr", `{"eventType": "Download", "series": {"title": "Show"}, "episodeFile": {"path": "/a.mkv"}}`, http.StatusBadRequest, "doesn't have any episodes"},
+		{"no movie file", "/radarr", `{"eventType": "Download", "movie": {"title": "Some Movie"}}`, http.StatusBadRequest, "doesn't have a movieFile path"},
+		{"no movie title", "/radarr", `{"eventType": "Download", "movieFile": {"sourcePath": "/a.mkv"}}`, http.StatusBadRequest, "doesn't have a movie title"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, r := send(t, h, test.path, "plexbot", "secret", test.body)
+			if status != test.status || !strings.Contains(r.Error, test.want) {
+				t.Errorf("Reply = %d %+v, want %d mentioning %q", status, r, test.status, test.want)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sonarr", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
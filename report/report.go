@@ -0,0 +1,228 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcome describes what happened to a single file during a run
+type Outcome string
+
+const (
+	// OutcomeMoved means the file was copied into the library
+	OutcomeMoved Outcome = "moved"
+
+	// OutcomeSkipped means the file was seen but deliberately left alone
+	OutcomeSkipped Outcome = "skipped"
+
+	// OutcomeParseFailed means the file couldn't be parsed and couldn't be
+	// tucked away in the errors path either
+	OutcomeParseFailed Outcome = "parse-failed"
+
+	// OutcomeErrorCopied means the file couldn't be parsed, so it was
+	// copied to the errors path instead
+	OutcomeErrorCopied Outcome = "error-copied"
+
+	// OutcomeFailed means the file was parsed but couldn't be moved
+	OutcomeFailed Outcome = "failed"
+)
+
+// PluginResult is the result of a single plugin execution
+type PluginResult struct {
+	Stage   string `json:"stage"`
+	Command string `json:"command"`
+	Output  string `json:"output,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	//	Set if the plugin's conditions didn't match, so it didn't run
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// FileResult is the result of processing a single file
+type FileResult struct {
+	File        string         `json:"file"`
+	Outcome     Outcome        `json:"outcome"`
+	Reason      string         `json:"reason,omitempty"`
+	Destination string         `json:"destination,omitempty"`
+	Bytes       int64          `json:"bytes"`
+	Plugins     []PluginResult `json:"plugins,omitempty"`
+}
+
+// Failed returns true if the file didn't make it into the library,
+// or if any of its plugins failed
+func (f FileResult) Failed() bool {
+	switch f.Outcome {
+	case OutcomeParseFailed, OutcomeErrorCopied, OutcomeFailed:
+		return true
+	}
+
+	for _, p := range f.Plugins {
+		if p.Error != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Totals are the summary counts for a run
+type Totals struct {
+	Files          int   `json:"files"`
+	Moved          int   `json:"moved"`
+	Skipped        int   `json:"skipped"`
+	ParseFailed    int   `json:"parsefailed"`
+	ErrorCopied    int   `json:"errorcopied"`
+	Failed         int   `json:"failed"`
+	PluginFailures int   `json:"pluginfailures"`
+	Bytes          int64 `json:"bytes"`
+}
+
+// Report is the machine-readable summary of a single run
+type Report struct {
+	RunID    string         `json:"runid,omitempty"`
+	Source   string         `json:"source"`
+	Hash     string         `json:"hash,omitempty"`
+	Tags     string         `json:"tags,omitempty"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Files    []FileResult   `json:"files"`
+	Plugins  []PluginResult `json:"plugins,omitempty"`
+	Totals   Totals         `json:"totals"`
+}
+
+// New creates a new report for a run against the given source directory
+func New(source, hash, tags string) *Report {
+	return &Report{
+		Source:  source,
+		Hash:    hash,
+		Tags:    tags,
+		Started: time.Now(),
+		Files:   []FileResult{},
+	}
+}
+
+// Add appends a file result to the report
+func (r *Report) Add(f FileResult) {
+	r.Files = append(r.Files, f)
+}
+
+// Finish stamps the finish time and calculates the run totals
+func (r *Report) Finish() {
+	r.Finished = time.Now()
+	r.Totals = Totals{Files: len(r.Files)}
+
+	for _, f := range r.Files {
+		switch f.Outcome {
+		case OutcomeMoved:
+			r.Totals.Moved++
+		case OutcomeSkipped:
+			r.Totals.Skipped++
+		case OutcomeParseFailed:
+			r.Totals.ParseFailed++
+		case OutcomeErrorCopied:
+			r.Totals.ErrorCopied++
+		case OutcomeFailed:
+			r.Totals.Failed++
+		}
+
+		r.Totals.Bytes += f.Bytes
+		r.Totals.PluginFailures += countPluginFailures(f.Plugins)
+	}
+
+	r.Totals.PluginFailures += countPluginFailures(r.Plugins)
+}
+
+// FailedFiles returns the number of files that failed in some way
+func (r *Report) FailedFiles() int {
+	failed := 0
+	for _, f := range r.Files {
+		if f.Failed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// HasFailures returns true if any file or run-level plugin failed
+func (r *Report) HasFailures() bool {
+	return r.FailedFiles() > 0 || countPluginFailures(r.Plugins) > 0
+}
+
+// WriteJSON writes the report to the given path as JSON
+func (r *Report) WriteJSON(path string) error {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("problem formatting report: %v", err)
+	}
+
+	return os.WriteFile(path, out, 0644)
+}
+
+// WriteCSV writes the report to the given path as CSV.  There is one row
+// per file, followed by a final row containing the run totals
+func (r *Report) WriteCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"file", "outcome", "reason", "destination", "bytes", "plugins"})
+
+	for _, file := range r.Files {
+		w.Write([]string{
+			file.File,
+			string(file.Outcome),
+			file.Reason,
+			file.Destination,
+			strconv.FormatInt(file.Bytes, 10),
+			formatPlugins(file.Plugins),
+		})
+	}
+
+	//	Add the totals as the last row:
+	t := r.Totals
+	w.Write([]string{
+		"(totals)",
+		"",
+		fmt.Sprintf("files=%d moved=%d skipped=%d parsefailed=%d errorcopied=%d failed=%d pluginfailures=%d", t.Files, t.Moved, t.Skipped, t.ParseFailed, t.ErrorCopied, t.Failed, t.PluginFailures),
+		"",
+		strconv.FormatInt(t.Bytes, 10),
+		formatPlugins(r.Plugins),
+	})
+
+	w.Flush()
+	return w.Error()
+}
+
+// formatPlugins flattens a list of plugin results into a single CSV field
+func formatPlugins(plugins []PluginResult) string {
+	var parts []string
+	for _, p := range plugins {
+		status := "ok"
+		if p.Error != "" {
+			status = p.Error
+		} else if p.Skipped {
+			status = "skipped"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s: %s", p.Stage, p.Command, status))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// countPluginFailures returns the number of failed plugins in the list
+func countPluginFailures(plugins []PluginResult) int {
+	failed := 0
+	for _, p := range plugins {
+		if p.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
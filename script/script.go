@@ -0,0 +1,305 @@
+package script
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/danesparza/plexbot/plugin"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// MaxSteps is the most steps a single call into a script can take before
+// it's stopped.  It keeps a runaway loop from hanging a run
+const MaxSteps = 1000000
+
+// Script is a compiled Starlark script.  It can define a parse function,
+// a destination function, and a list of tests:
+//
+//	def parse(filename, dirs):
+//	    return {"showname": "...", "season": 1, "episode": 2}
+//
+//	def destination(info):
+//	    return "Show/Season 1/s1e02.mkv"
+//
+//	tests = [("Show.Name.S01E02.mkv", "Show Name/Season 1/s1e02.mkv")]
+//
+// Scripts can't load other files or touch the filesystem, the network or the
+// clock, so the same input always gives the same result.  A Script can be
+// called from more than one goroutine
+type Script struct {
+	Path string
+
+	parse       starlark.Callable
+	destination starlark.Callable
+	tests       starlark.Value
+}
+
+// Test is a single test case defined in a script
+type Test struct {
+	// File is the file name, with any directories it's in (like Show/Season 1/e02.mkv)
+	File string
+
+	// Want is the expected destination, relative to the Plex TV path.
+	// It's empty if the file shouldn't be parsed
+	Want string
+}
+
+// Load reads and compiles the script at the given path
+func Load(path string) (*Script, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the script: %v", err)
+	}
+
+	globals, err := starlark.ExecFile(newThread(path), path, src, predeclared())
+	if err != nil {
+		return nil, describe(err)
+	}
+
+	s := &Script{Path: path, tests: globals["tests"]}
+	for name, fn := range map[string]*starlark.Callable{"parse": &s.parse, "destination": &s.destination} {
+		value, ok := globals[name]
+		if !ok {
+			continue
+		}
+		if *fn, ok = value.(starlark.Callable); !ok {
+			return nil, fmt.Errorf("%v: %v should be a function, not a %v", path, name, value.Type())
+		}
+	}
+
+	if s.parse == nil && s.destination == nil {
+		return nil, fmt.Errorf("%v doesn't define a parse or destination function", path)
+	}
+
+	return s, nil
+}
+
+// HasParse returns true if the script defines a parse function
+func (s *Script) HasParse() bool {
+	return s.parse != nil
+}
+
+// Parse calls the script's parse function with the file name and the
+// directories it's in (below the source directory).  It returns nil if the
+// script doesn't have a parse function, or if it returned None
+func (s *Script) Parse(filename string, dirs []string) (*plugin.ParseInfo, error) {
+	if s.parse == nil {
+		return nil, nil
+	}
+
+	list := make([]starlark.Value, len(dirs))
+	for i, dir := range dirs {
+		list[i] = starlark.String(dir)
+	}
+
+	result, err := s.call(s.parse, starlark.String(filename), starlark.NewList(list))
+	if err != nil || result == starlark.None {
+		return nil, err
+	}
+
+	fields, err := fieldsOf(result)
+	if err != nil {
+		return nil, fmt.Errorf("%v: parse should return a dict or None: %v", s.Path, err)
+	}
+
+	info := &plugin.ParseInfo{}
+	for _, name := range sortedKeys(fields) {
+		value := fields[name]
+		switch name {
+		case "showname":
+			show, ok := starlark.AsString(value)
+			if !ok {
+				return nil, fmt.Errorf("%v: parse returned a showname that isn't a string", s.Path)
+			}
+			info.ShowName = show
+		case "season", "episode", "airedyear", "airedmonth", "airedday":
+			number, err := starlark.AsInt32(value)
+			if err != nil {
+				return nil, fmt.Errorf("%v: parse returned a %v that isn't a number: %v", s.Path, name, err)
+			}
+			switch name {
+			case "season":
+				info.SeasonNumber = number
+			case "episode":
+				info.EpisodeNumber = number
+			case "airedyear":
+				info.AiredYear = number
+			case "airedmonth":
+				info.AiredMonth = number
+			case "airedday":
+				info.AiredDay = number
+			}
+		default:
+			return nil, fmt.Errorf("%v: parse returned an unknown field %q", s.Path, name)
+		}
+	}
+
+	_, hasSeason := fields["season"]
+	_, hasEpisode := fields["episode"]
+	_, hasYear := fields["airedyear"]
+	switch {
+	case info.ShowName == "":
+		return nil, fmt.Errorf("%v: parse didn't return a showname", s.Path)
+	case hasYear && !hasSeason && !hasEpisode:
+		info.ParseType = "date"
+	case hasSeason || hasEpisode:
+		info.ParseType = "se"
+	default:
+		return nil, fmt.Errorf("%v: parse should return a season and episode, or an airedyear", s.Path)
+	}
+
+	return info, nil
+}
+
+// Destination calls the script's destination function with the plan for a
+// file.  It returns the new destination (relative paths are relative to the
+// Plex TV path), or an empty string to keep the plan's destination
+func (s *Script) Destination(plan *plugin.Plan, filename, extension, tvpath string) (string, error) {
+	if s.destination == nil {
+		return "", nil
+	}
+
+	info := starlarkstruct.FromStringDict(starlark.String("info"), starlark.StringDict{
+		"filename":    starlark.String(filename),
+		"extension":   starlark.String(extension),
+		"tvpath":      starlark.String(tvpath),
+		"parsetype":   starlark.String(plan.ParseType),
+		"showname":    starlark.String(plan.ShowName),
+		"showyear":    starlark.MakeInt(plan.ShowYear),
+		"season":      starlark.MakeInt(plan.SeasonNumber),
+		"episode":     starlark.MakeInt(plan.EpisodeNumber),
+		"airedyear":   starlark.MakeInt(plan.AiredYear),
+		"airedmonth":  starlark.MakeInt(plan.AiredMonth),
+		"airedday":    starlark.MakeInt(plan.AiredDay),
+		"library":     starlark.String(plan.Library),
+		"destination": starlark.String(plan.Destination),
+	})
+
+	result, err := s.call(s.destination, info)
+	if err != nil || result == starlark.None {
+		return "", err
+	}
+
+	destination, ok := starlark.AsString(result)
+	if !ok {
+		return "", fmt.Errorf("%v: destination should return a string or None, not a %v", s.Path, result.Type())
+	}
+	return destination, nil
+}
+
+// Tests returns the tests defined in the script's tests list
+func (s *Script) Tests() ([]Test, error) {
+	if s.tests == nil {
+		return nil, nil
+	}
+
+	iterable, ok := s.tests.(starlark.Iterable)
+	if !ok {
+		return nil, fmt.Errorf("%v: tests should be a list, not a %v", s.Path, s.tests.Type())
+	}
+
+	var tests []Test
+	iter := iterable.Iterate()
+	defer iter.Done()
+
+	var item starlark.Value
+	for iter.Next(&item) {
+		pair, ok := item.(starlark.Tuple)
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("%v: each test should be a (file, destination) pair, not %v", s.Path, item)
+		}
+
+		file, ok := starlark.AsString(pair[0])
+		if !ok {
+			return nil, fmt.Errorf("%v: the test file %v isn't a string", s.Path, pair[0])
+		}
+
+		test := Test{File: file}
+		if pair[1] != starlark.None {
+			if test.Want, ok = starlark.AsString(pair[1]); !ok {
+				return nil, fmt.Errorf("%v: the destination for %v should be a string or None", s.Path, file)
+			}
+		}
+		tests = append(tests, test)
+	}
+
+	return tests, nil
+}
+
+// call runs a script function on a new thread
+func (s *Script) call(fn starlark.Callable, args ...starlark.Value) (starlark.Value, error) {
+	result, err := starlark.Call(newThread(s.Path), fn, args, nil)
+	if err != nil {
+		return nil, describe(err)
+	}
+	return result, nil
+}
+
+// newThread returns a thread for running the script.  It can't load other
+// files, and it's stopped if it runs for too long
+func newThread(path string) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: path,
+		Print: func(_ *starlark.Thread, msg string) {
+			log.Printf("[INFO] -- %v: %v", path, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(MaxSteps)
+	return thread
+}
+
+// predeclared returns the values scripts can use beyond the Starlark builtins
+func predeclared() starlark.StringDict {
+	return starlark.StringDict{
+		"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+	}
+}
+
+// describe includes the script backtrace in evaluation errors
+func describe(err error) error {
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		return fmt.Errorf("%v", evalErr.Backtrace())
+	}
+	return err
+}
+
+// fieldsOf returns the fields in a dict or struct
+func fieldsOf(value starlark.Value) (map[string]starlark.Value, error) {
+	fields := make(map[string]starlark.Value)
+
+	switch typed := value.(type) {
+	case *starlark.Dict:
+		for _, item := range typed.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("the key %v isn't a string", item[0])
+			}
+			fields[key] = item[1]
+		}
+	case *starlarkstruct.Struct:
+		for _, name := range typed.AttrNames() {
+			attr, err := typed.Attr(name)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = attr
+		}
+	default:
+		return nil, fmt.Errorf("got a %v", value.Type())
+	}
+
+	return fields, nil
+}
+
+// sortedKeys returns the keys of the map in order
+func sortedKeys(m map[string]starlark.Value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
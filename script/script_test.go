@@ -0,0 +1,197 @@
+package script
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// load writes the source to a script file and loads it
+func load(t *testing.T, src string) (*Script, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rules.star")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+// mustLoad loads the script, failing the test if it can't be
+func mustLoad(t *testing.T, src string) *Script {
+	t.Helper()
+
+	s, err := load(t, src)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return s
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"doesn't compile", "def parse(filename, dirs)\n    return None\n", "got newline"},
+		{"unknown name", "def parse(filename, dirs):\n    return nope\n", "undefined: nope"},
+		{"no functions", "tests = []\n", "doesn't define a parse or destination function"},
+		{"parse isn't a function", "parse = 1\n", "parse should be a function"},
+		{"fails when run", "x = 1 // 0\n\ndef parse(filename, dirs):\n    return None\n", "division by zero"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := load(t, test.src)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Load error = %v, want it to mention %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	s := mustLoad(t, `
+def parse(filename, dirs):
+    if filename.startswith("TDS"):
+        return {"showname": "The Daily Show", "airedyear": 2024, "airedmonth": 3, "airedday": 5}
+    if dirs:
+        return struct(showname = dirs[0], season = 1, episode = int(filename[1:3]))
+    return None
+`)
+
+	tests := []struct {
+		filename string
+		dirs     []string
+		want     *plugin.ParseInfo
+	}{
+		{"TDS.240305.mkv", nil, &plugin.ParseInfo{ParseType: "date", ShowName: "The Daily Show", AiredYear: 2024, AiredMonth: 3, AiredDay: 5}},
+		{"e07.mkv", []string{"Show Name"}, &plugin.ParseInfo{ParseType: "se", ShowName: "Show Name", SeasonNumber: 1, EpisodeNumber: 7}},
+		{"Something.mkv", nil, nil},
+	}
+
+	for _, test := range tests {
+		got, err := s.Parse(test.filename, test.dirs)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.filename, err)
+			continue
+		}
+
+		switch {
+		case test.want == nil && got != nil:
+			t.Errorf("Parse(%q) = %+v, want nil", test.filename, *got)
+		case test.want != nil && got == nil:
+			t.Errorf("Parse(%q) = nil, want %+v", test.filename, *test.want)
+		case test.want != nil && !reflect.DeepEqual(*got, *test.want):
+			t.Errorf("Parse(%q) = %+v, want %+v", test.filename, *got, *test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"wrong return type", `def parse(filename, dirs): return "Show Name"`, "parse should return a dict or None"},
+		{"season isn't a number", `def parse(filename, dirs): return {"showname": "Show", "season": "1", "episode": 2}`, "season that isn't a number"},
+		{"unknown field", `def parse(filename, dirs): return {"showname": "Show", "season": 1, "episode": 2, "title": "x"}`, `unknown field "title"`},
+		{"no show name", `def parse(filename, dirs): return {"season": 1, "episode": 2}`, "didn't return a showname"},
+		{"no numbers", `def parse(filename, dirs): return {"showname": "Show"}`, "should return a season and episode, or an airedyear"},
+		{"changes a frozen global", "seen = []\n\ndef parse(filename, dirs):\n    seen.append(filename)\n    return None\n", "frozen"},
+		{"runs too long", "def parse(filename, dirs):\n    for i in range(100000000):\n        pass\n", "too many steps"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := mustLoad(t, test.src)
+			_, err := s.Parse("Show.Name.S01E02.mkv", []string{})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Parse error = %v, want it to mention %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestDestination(t *testing.T) {
+	s := mustLoad(t, `
+def destination(info):
+    if info.parsetype == "date":
+        return None
+    return "%s/Season %d/%s - %dx%d%s" % (info.showname, info.season, info.showname, info.season, info.episode, info.extension)
+`)
+
+	if s.HasParse() {
+		t.Error("HasParse = true for a script without a parse function")
+	}
+
+	plan := &plugin.Plan{ParseInfo: plugin.ParseInfo{ParseType: "se", ShowName: "Show Name", SeasonNumber: 1, EpisodeNumber: 2}}
+	got, err := s.Destination(plan, "Show.Name.S01E02.mkv", ".mkv", "/tv")
+	if want := "Show Name/Season 1/Show Name - 1x2.mkv"; err != nil || got != want {
+		t.Errorf("Destination = %q, %v, want %q", got, err, want)
+	}
+
+	plan.ParseType = "date"
+	if got, err := s.Destination(plan, "Show.Name.2024.03.05.mkv", ".mkv", "/tv"); err != nil || got != "" {
+		t.Errorf("Destination for None = %q, %v, want the plan's destination kept", got, err)
+	}
+
+	wrong := mustLoad(t, `def destination(info): return 42`)
+	if _, err := wrong.Destination(plan, "Show.Name.S01E02.mkv", ".mkv", "/tv"); err == nil || !strings.Contains(err.Error(), "should return a string or None") {
+		t.Errorf("Destination error = %v, want a wrong return type error", err)
+	}
+
+	frozen := mustLoad(t, "names = {}\n\ndef destination(info):\n    names[info.showname] = True\n    return None\n")
+	if _, err := frozen.Destination(plan, "Show.Name.S01E02.mkv", ".mkv", "/tv"); err == nil || !strings.Contains(err.Error(), "frozen") {
+		t.Errorf("Destination error = %v, want a frozen dict error", err)
+	}
+}
+
+func TestTests(t *testing.T) {
+	s := mustLoad(t, `
+def destination(info):
+    return None
+
+tests = [
+    ("Show.Name.S01E02.mkv", "Show Name/Season 1/s1e02.mkv"),
+    ("Home.Video.mkv", None),
+]
+`)
+
+	tests, err := s.Tests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Test{{File: "Show.Name.S01E02.mkv", Want: "Show Name/Season 1/s1e02.mkv"}, {File: "Home.Video.mkv"}}
+	if len(tests) != len(want) {
+		t.Fatalf("Tests = %+v, want %+v", tests, want)
+	}
+	for i := range want {
+		if tests[i] != want[i] {
+			t.Errorf("Tests[%d] = %+v, want %+v", i, tests[i], want[i])
+		}
+	}
+
+	for src, message := range map[string]string{
+		"def destination(info): return None\ntests = 1\n":                "tests should be a list",
+		"def destination(info): return None\ntests = [(\"a.mkv\",)]\n":   "should be a (file, destination) pair",
+		"def destination(info): return None\ntests = [(1, None)]\n":      "isn't a string",
+		"def destination(info): return None\ntests = [(\"a.mkv\", 2)]\n": "should be a string or None",
+		"def destination(info): return None\n":                           "",
+	} {
+		s := mustLoad(t, src)
+		_, err := s.Tests()
+		switch {
+		case message == "" && err != nil:
+			t.Errorf("Tests for %q: %v", src, err)
+		case message != "" && (err == nil || !strings.Contains(err.Error(), message)):
+			t.Errorf("Tests error for %q = %v, want it to mention %q", src, err, message)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package torrent
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// maxDepth is how deeply lists and dictionaries can be nested.  Real
+// torrents only go a few levels deep
+const maxDepth = 64
+
+// decoder reads bencoded data.  Values are decoded as int64, string,
+// []interface{} or map[string]interface{}
+type decoder struct {
+	data []byte
+	pos  int
+
+	//	Where each of the top level dictionary values starts and ends,
+	//	so the info dictionary can be hashed exactly as it was written
+	spans map[string][2]int
+}
+
+// decode reads a single bencoded value, which has to be all of the data
+func decode(data []byte) (interface{}, map[string][2]int, error) {
+	d := &decoder{data: data, spans: make(map[string][2]int)}
+
+	value, err := d.value(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, nil, fmt.Errorf("unexpected data at offset %d", d.pos)
+	}
+
+	return value, d.spans, nil
+}
+
+// value reads the next value
+func (d *decoder) value(depth int) (interface{}, error) {
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	if depth > maxDepth {
+		return nil, fmt.Errorf("values nested too deeply at offset %d", d.pos)
+	}
+
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		return d.integer()
+	case c == 'l':
+		return d.list(depth)
+	case c == 'd':
+		return d.dict(depth)
+	case c >= '0' && c <= '9':
+		return d.str()
+	default:
+		return nil, fmt.Errorf("unexpected %q at offset %d", c, d.pos)
+	}
+}
+
+// integer reads an integer like i42e
+func (d *decoder) integer() (int64, error) {
+	start := d.pos + 1
+	end := d.find('e', start)
+	if end < 0 {
+		return 0, fmt.Errorf("unterminated integer at offset %d", d.pos)
+	}
+
+	n, err := strconv.ParseInt(string(d.data[start:end]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad integer at offset %d: %v", d.pos, err)
+	}
+
+	d.pos = end + 1
+	return n, nil
+}
+
+// str reads a string like 4:spam
+func (d *decoder) str() (string, error) {
+	colon := d.find(':', d.pos)
+	if colon < 0 {
+		return "", fmt.Errorf("unterminated string length at offset %d", d.pos)
+	}
+
+	length, err := strconv.Atoi(string(d.data[d.pos:colon]))
+	if err != nil || length < 0 {
+		return "", fmt.Errorf("bad string length at offset %d", d.pos)
+	}
+
+	start := colon + 1
+	if length > len(d.data)-start {
+		return "", fmt.Errorf("string at offset %d runs past the end of the data", d.pos)
+	}
+
+	d.pos = start + length
+	return string(d.data[start:d.pos]), nil
+}
+
+// list reads a list like l4:spami42ee
+func (d *decoder) list(depth int) ([]interface{}, error) {
+	d.pos++
+
+	list := []interface{}{}
+	for {
+		if d.pos >= len(d.data) {
+			return nil, fmt.Errorf("unterminated list")
+		}
+		if d.data[d.pos] == 'e' {
+			d.pos++
+			return list, nil
+		}
+
+		item, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+}
+
+// dict reads a dictionary like d3:cow3:mooe
+func (d *decoder) dict(depth int) (map[string]interface{}, error) {
+	d.pos++
+
+	dict := make(map[string]interface{})
+	for {
+		if d.pos >= len(d.data) {
+			return nil, fmt.Errorf("unterminated dictionary")
+		}
+		if d.data[d.pos] == 'e' {
+			d.pos++
+			return dict, nil
+		}
+
+		key, err := d.str()
+		if err != nil {
+			return nil, fmt.Errorf("bad dictionary key: %v", err)
+		}
+
+		start := d.pos
+		item, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if depth == 0 {
+			d.spans[key] = [2]int{start, d.pos}
+		}
+		dict[key] = item
+	}
+}
+
+// find returns the index of the next c, starting at from
+func (d *decoder) find(c byte, from int) int {
+	for i := from; i < len(d.data); i++ {
+		if d.data[i] == c {
+			return i
+		}
+	}
+	return -1
+}
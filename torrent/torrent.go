@@ -0,0 +1,219 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxSize is the largest .torrent file that will be read
+const MaxSize = 16 << 20
+
+// Torrent is the information plexbot uses from a .torrent file
+type Torrent struct {
+	// Name is the torrent name.  It's the file name for single file
+	// torrents, and the folder name for multi file torrents
+	Name string
+
+	// InfoHash is the hex encoded SHA-1 hash of the info dictionary
+	InfoHash string
+
+	// Files are the files in the torrent
+	Files []File
+
+	//	Set for torrents with a folder of files
+	multiFile bool
+}
+
+// File is a single file in a torrent
+type File struct {
+	// Path is the path to the file inside the torrent folder
+	Path   string
+	Length int64
+}
+
+// Load reads the .torrent file at the given path
+func Load(path string) (*Torrent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	//	Read one byte past the limit, to tell if the file is too big
+	data, err := io.ReadAll(io.LimitReader(f, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("problem reading %v: %v", path, err)
+	}
+	return t, nil
+}
+
+// Parse reads a bencoded .torrent file
+func Parse(data []byte) (*Torrent, error) {
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("the torrent is bigger than %d bytes", MaxSize)
+	}
+
+	value, spans, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a torrent file")
+	}
+
+	info, ok := root["info"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the torrent doesn't have an info dictionary")
+	}
+
+	span := spans["info"]
+	sum := sha1.Sum(data[span[0]:span[1]])
+	t := &Torrent{InfoHash: hex.EncodeToString(sum[:])}
+
+	//	Prefer the UTF-8 name if the torrent has one
+	t.Name, _ = info["name"].(string)
+	if name, ok := info["name.utf-8"].(string); ok {
+		t.Name = name
+	}
+	if t.Name == "" {
+		return nil, fmt.Errorf("the torrent doesn't have a name")
+	}
+	if !safePart(t.Name) {
+		return nil, fmt.Errorf("the torrent name %q isn't a plain file or folder name", t.Name)
+	}
+
+	if files, ok := info["files"].([]interface{}); ok {
+		t.multiFile = true
+		for _, item := range files {
+			file, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("bad file entry in the torrent")
+			}
+
+			parts, ok := file["path.utf-8"].([]interface{})
+			if !ok {
+				parts, _ = file["path"].([]interface{})
+			}
+
+			var path []string
+			for _, part := range parts {
+				if s, ok := part.(string); ok {
+					if !safePart(s) {
+						return nil, fmt.Errorf("a file in the torrent has an unsafe path: %q", s)
+					}
+					path = append(path, s)
+				}
+			}
+			if len(path) == 0 {
+				return nil, fmt.Errorf("a file in the torrent doesn't have a path")
+			}
+
+			//	Skip the padding files some clients add
+			if attr, _ := file["attr"].(string); strings.Contains(attr, "p") {
+				continue
+			}
+
+			length, _ := file["length"].(int64)
+			t.Files = append(t.Files, File{Path: filepath.Join(path...), Length: length})
+		}
+		return t, nil
+	}
+
+	length, ok := info["length"].(int64)
+	if !ok {
+		return nil, fmt.Errorf("the torrent doesn't list any files (only version 1 torrents are supported)")
+	}
+	t.Files = []File{{Path: t.Name, Length: length}}
+
+	return t, nil
+}
+
+// Locate returns the full paths of the torrent's files, given the directory
+// the content was passed as.  That can be the torrent's own folder (or file),
+// or the download directory it was saved in.  Files that would be outside
+// the torrent's folder (like ../../.bashrc) are left out
+func (t *Torrent) Locate(dir string) []string {
+	if !safePart(t.Name) {
+		return nil
+	}
+
+	root := dir
+	switch {
+	case !t.multiFile && filepath.Base(dir) == t.Name:
+		root = filepath.Dir(dir)
+	case t.multiFile && filepath.Base(dir) == t.Name:
+		root = dir
+	case t.multiFile:
+		root = filepath.Join(dir, t.Name)
+	}
+
+	var paths []string
+	for _, file := range t.Files {
+		if filepath.IsAbs(file.Path) {
+			continue
+		}
+		path := filepath.Join(root, file.Path)
+		if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// safePart returns true if the name is a single file or folder name, not
+// a path or a reference to the current or parent folder
+func safePart(name string) bool {
+	switch name {
+	case "", ".", "..":
+		return false
+	}
+	return !strings.ContainsAny(name, "/\\\x00")
+}
+
+// Find looks for a .torrent file for the content in the given directory:
+// in the directory itself, and next to it.  If a hash is given, only a
+// torrent with that info hash matches.  Otherwise the torrent's name has to
+// match the directory name.  It returns nil if there isn't exactly one match
+func Find(dir, hash string) *Torrent {
+	var candidates []string
+	for _, search := range []string{dir, filepath.Dir(dir)} {
+		matches, _ := filepath.Glob(filepath.Join(search, "*.torrent"))
+		candidates = append(candidates, matches...)
+	}
+
+	var found *Torrent
+	for _, candidate := range candidates {
+		t, err := Load(candidate)
+		if err != nil {
+			continue
+		}
+
+		matched := strings.EqualFold(t.InfoHash, hash)
+		if hash == "" {
+			matched = t.Name == filepath.Base(dir)
+		}
+		if !matched {
+			continue
+		}
+
+		if found != nil && found.InfoHash != t.InfoHash {
+			return nil
+		}
+		found = t
+	}
+
+	return found
+}
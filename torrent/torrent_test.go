@@ -0,0 +1,196 @@
+package torrent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The info dictionaries of a single file and a multi file torrent.  The
+// hashes were worked out separately, from the bytes of each dictionary
+const (
+	singleInfo = "d6:lengthi1024e4:name20:Show.Name.S01E02.mkv12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaae"
+	singleHash = "f5cc5c8b0dcb0c63bdad542ec0959144f84b5d52"
+
+	multiInfo = "d5:filesld6:lengthi1024e4:pathl20:Show.Name.S01E01.mkveed6:lengthi10e4:pathl4:Subs7:eng.srteed4:attr1:p6:lengthi5e4:pathl4:.pad1:5eee4:name18:Show.Name.S01.x26412:piece lengthi16384e6:pieces20:bbbbbbbbbbbbbbbbbbbbe"
+	multiHash = "ce3697246544f0addd180227f45fa8a47b703fdf"
+)
+
+// torrentFile wraps an info dictionary in a .torrent file
+func torrentFile(info string) string {
+	return "d8:announce23:http://tracker/announce4:info" + info + "e"
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Torrent
+	}{
+		{
+			"single file", torrentFile(singleInfo),
+			Torrent{Name: "Show.Name.S01E02.mkv", InfoHash: singleHash, Files: []File{{Path: "Show.Name.S01E02.mkv", Length: 1024}}},
+		},
+		{
+			"multi file", torrentFile(multiInfo),
+			Torrent{Name: "Show.Name.S01.x264", InfoHash: multiHash, multiFile: true, Files: []File{{Path: "Show.Name.S01E01.mkv", Length: 1024}, {Path: filepath.Join("Subs", "eng.srt"), Length: 10}}},
+		},
+		{
+			"utf-8 name", torrentFile("d6:lengthi1e4:name5:Other10:name.utf-85:Namede"),
+			Torrent{Name: "Named", InfoHash: "", Files: []File{{Path: "Named", Length: 1}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse([]byte(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			//	Only the known torrents have a hash to check
+			if test.want.InfoHash == "" {
+				test.want.InfoHash = got.InfoHash
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("Parse = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", "", "unexpected end of data"},
+		{"truncated dictionary", "d8:announce3:url", "unterminated dictionary"},
+		{"truncated list", "d4:infod5:filesl", "unterminated list"},
+		{"truncated integer", "d4:infod6:lengthi12", "unterminated integer"},
+		{"truncated string length", "d4:infod4", "unterminated string length"},
+		{"string past the end", "d4:info10:short", "runs past the end of the data"},
+		{"huge string length", "d4:info99999999999999999999:x", "bad string length"},
+		{"integer too big", "d4:infoi99999999999999999999ee", "bad integer"},
+		{"bad integer", "d4:infoi12xee", "bad integer"},
+		{"negative string length", "d-1:xe", "bad string length"},
+		{"not a value", "x", `unexpected 'x'`},
+		{"key isn't a string", "di1e1:xe", "bad dictionary key"},
+		{"trailing data", torrentFile(singleInfo) + "junk", "unexpected data"},
+		{"nested too deeply", strings.Repeat("l", maxDepth+2) + strings.Repeat("e", maxDepth+2), "nested too deeply"},
+		{"not a dictionary", "l4:infoe", "not a torrent file"},
+		{"no info", "d8:announce3:urle", "doesn't have an info dictionary"},
+		{"no name", torrentFile("d6:lengthi1ee"), "doesn't have a name"},
+		{"no files", torrentFile("d4:name4:Showe"), "doesn't list any files"},
+		{"unsafe name", torrentFile("d6:lengthi1e4:name2:..e"), "isn't a plain file or folder name"},
+		{"unsafe file path", torrentFile("d5:filesld6:lengthi1e4:pathl2:..7:.bashrceee4:name4:Showe"), "unsafe path"},
+		{"file without a path", torrentFile("d5:filesld6:lengthi1eee4:name4:Showe"), "doesn't have a path"},
+		{"bad file entry", torrentFile("d5:filesli1ee4:name4:Showe"), "bad file entry"},
+		{"too big", torrentFile(singleInfo) + strings.Repeat(" ", MaxSize), "bigger than"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse([]byte(test.data))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Parse = %+v, %v, want an error mentioning %q", got, err, test.want)
+			}
+		})
+	}
+
+	//	Nesting right up to the limit is fine, it's just not a torrent
+	nested := strings.Repeat("l", maxDepth+1) + strings.Repeat("e", maxDepth+1)
+	if _, err := Parse([]byte(nested)); err == nil || !strings.Contains(err.Error(), "not a torrent file") {
+		t.Errorf("Parse at the nesting limit = %v, want it decoded", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "show.torrent")
+	if err := os.WriteFile(path, []byte(torrentFile(multiInfo)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Load(path); err != nil || got.InfoHash != multiHash {
+		t.Errorf("Load = %+v, %v, want the torrent with hash %v", got, err, multiHash)
+	}
+
+	//	Files over the limit aren't read in full
+	big := filepath.Join(dir, "big.torrent")
+	if err := os.WriteFile(big, make([]byte, MaxSize+10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(big); err == nil || !strings.Contains(err.Error(), "bigger than") {
+		t.Errorf("Load of a big file = %v, want it refused", err)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	single, err := Parse([]byte(torrentFile(singleInfo)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi, err := Parse([]byte(torrentFile(multiInfo)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	downloads := filepath.Join(string(filepath.Separator), "downloads")
+	content := filepath.Join(downloads, "Show.Name.S01.x264")
+
+	tests := []struct {
+		name    string
+		torrent *Torrent
+		dir     string
+		want    []string
+	}{
+		{"single file passed as the file", single, filepath.Join(downloads, "Show.Name.S01E02.mkv"), []string{filepath.Join(downloads, "Show.Name.S01E02.mkv")}},
+		{"single file in the download directory", single, downloads, []string{filepath.Join(downloads, "Show.Name.S01E02.mkv")}},
+		{"multi file content directory", multi, content, []string{filepath.Join(content, "Show.Name.S01E01.mkv"), filepath.Join(content, "Subs", "eng.srt")}},
+		{"multi file download directory", multi, downloads, []string{filepath.Join(content, "Show.Name.S01E01.mkv"), filepath.Join(content, "Subs", "eng.srt")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.torrent.Locate(test.dir); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Locate(%q) = %q, want %q", test.dir, got, test.want)
+			}
+		})
+	}
+
+	//	Files are never located outside the torrent's folder
+	unsafe := &Torrent{Name: "Show", multiFile: true, Files: []File{{Path: filepath.Join("..", ".bashrc")}, {Path: filepath.Join(string(filepath.Separator), "etc", "passwd")}, {Path: "ok.mkv"}}}
+	if got, want := unsafe.Locate(downloads), []string{filepath.Join(downloads, "Show", "ok.mkv")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Locate with unsafe paths = %q, want %q", got, want)
+	}
+	if got := (&Torrent{Name: ".."}).Locate(downloads); got != nil {
+		t.Errorf("Locate with an unsafe name = %q, want nothing", got)
+	}
+}
+
+func TestSafePart(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Show.Name.S01E02.mkv", true},
+		{"..Show", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"/etc", false},
+		{"Subs/eng.srt", false},
+		{`C:\Windows`, false},
+		{"name\x00.mkv", false},
+	}
+
+	for _, test := range tests {
+		if got := safePart(test.name); got != test.want {
+			t.Errorf("safePart(%q) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// The events a webhook can fire on
+const (
+	// EventFileMoved fires when a file is moved into the library
+	EventFileMoved = "file-moved"
+
+	// EventFileFailed fires when a file was parsed, but couldn't be moved
+	EventFileFailed = "file-failed"
+
+	// EventParseFailed fires when a file couldn't be parsed
+	EventParseFailed = "parse-failed"
+
+	// EventRunCompleted fires once all of the files in a run have been processed
+	EventRunCompleted = "run-completed"
+)
+
+// Events is the list of events a webhook can fire on
+var Events = []string{EventFileMoved, EventFileFailed, EventParseFailed, EventRunCompleted}
+
+// DefaultSignatureHeader is the header the HMAC signature is sent in,
+// if the webhook doesn't name one
+const DefaultSignatureHeader = "X-Plexbot-Signature"
+
+// Hook holds the settings for a single outbound webhook
+type Hook struct {
+	// URL is where the webhook gets sent
+	URL string `yaml:"url" json:"url" toml:"url" mapstructure:"url"`
+
+	// Method is the http method to use.  It defaults to POST
+	Method string `yaml:"method,omitempty" json:"method,omitempty" toml:"method,omitempty" mapstructure:"method"`
+
+	// Headers are added to each request
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" toml:"headers,omitempty" mapstructure:"headers"`
+
+	// Body is a Go template for the request body.  It gets the Data for the
+	// event.  If it's not set, the Data is sent as JSON
+	Body string `yaml:"body,omitempty" json:"body,omitempty" toml:"body,omitempty" mapstructure:"body"`
+
+	// Events is the list of events to fire on.  It defaults to all of them
+	Events []string `yaml:"events,omitempty" json:"events,omitempty" toml:"events,omitempty" mapstructure:"events"`
+
+	// Retries is the number of times to retry a failed request
+	Retries int `yaml:"retries,omitempty" json:"retries,omitempty" toml:"retries,omitempty" mapstructure:"retries"`
+
+	// Backoff is how long to wait before the first retry (like 2s).
+	// It doubles for each retry after that
+	Backoff string `yaml:"backoff,omitempty" json:"backoff,omitempty" toml:"backoff,omitempty" mapstructure:"backoff"`
+
+	// Secret is used to sign the body with HMAC-SHA256.  If it's set, the
+	// signature is sent in the signature header as sha256=<hex>
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty" toml:"secret,omitempty" mapstructure:"secret"`
+
+	// SignatureHeader is the header the signature is sent in
+	SignatureHeader string `yaml:"signatureheader,omitempty" json:"signatureheader,omitempty" toml:"signatureheader,omitempty" mapstructure:"signatureheader"`
+}
+
+// Data is what the body template gets.  It has the event name, along with
+// the same information plugins get on stdin
+type Data struct {
+	Name string `json:"event"`
+	*plugin.Event
+}
+
+// FiresOn returns true if the webhook should fire for the given event
+func (h Hook) FiresOn(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+
+	for _, e := range h.Events {
+		if strings.EqualFold(e, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses the body template
+func (h Hook) Parse() (*template.Template, error) {
+	return template.New("body").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			out, err := json.Marshal(v)
+			return string(out), err
+		},
+		"token": func(name string) string { return "" },
+	}).Option("missingkey=zero").Parse(h.Body)
+}
+
+// BackoffDuration returns the time to wait before the first retry
+func (h Hook) BackoffDuration() (time.Duration, error) {
+	if h.Backoff == "" {
+		return time.Second, nil
+	}
+	return time.ParseDuration(h.Backoff)
+}
+
+// Send formats the body for the event and sends the webhook, retrying with
+// backoff if it fails.  It returns a description of the response
+func (h Hook) Send(data Data) (string, error) {
+	body, err := h.format(data)
+	if err != nil {
+		return "", err
+	}
+
+	backoff, err := h.BackoffDuration()
+	if err != nil {
+		return "", err
+	}
+
+	var output string
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		output, retry, err = h.send(body)
+		if err == nil || !retry || attempt >= h.Retries {
+			return output, err
+		}
+
+		time.Sleep(backoff << uint(attempt))
+	}
+}
+
+// format executes the body template (or formats the data as JSON)
+func (h Hook) format(data Data) ([]byte, error) {
+	if h.Body == "" {
+		return json.Marshal(data)
+	}
+
+	tmpl, err := h.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("problem parsing the webhook body: %v", err)
+	}
+
+	//	Tokens can be looked up by name, like {{token "showname"}}
+	tmpl = tmpl.Funcs(template.FuncMap{
+		"token": func(name string) string {
+			return data.Tokens["{"+strings.Trim(name, "{}")+"}"]
+		},
+	})
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("problem formatting the webhook body: %v", err)
+	}
+	return body.Bytes(), nil
+}
+
+// send makes a single request.  It returns whether a failed request is worth retrying
+func (h Hook) send(body []byte) (string, bool, error) {
+	method := http.MethodPost
+	if h.Method != "" {
+		method = strings.ToUpper(h.Method)
+	}
+
+	req, err := http.NewRequest(method, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range h.Headers {
+		req.Header.Set(name, value)
+	}
+
+	if h.Secret != "" {
+		header := h.SignatureHeader
+		if header == "" {
+			header = DefaultSignatureHeader
+		}
+		req.Header.Set(header, "sha256="+Sign(body, h.Secret))
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", true, err
+	}
+	defer resp.Body.Close()
+
+	//	Keep a little bit of the response for the report
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	output := strings.TrimSpace(fmt.Sprintf("%s %s", resp.Status, response))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return output, retry, fmt.Errorf("%s %s returned %s", method, h.URL, resp.Status)
+	}
+
+	return output, false, nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of the body
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/danesparza/plexbot/plugin"
+)
+
+// request is what the test server got
+type request struct {
+	method string
+	header http.Header
+	body   string
+}
+
+// server replies to each request with the next status (repeating the last
+// one), and keeps the requests it got
+func server(t *testing.T, statuses ...int) (*httptest.Server, func() []request) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+		status := statuses[len(statuses)-1]
+		if len(requests) < len(statuses) {
+			status = statuses[len(requests)]
+		}
+		requests = append(requests, request{method: req.Method, header: req.Header, body: string(body)})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+// movedData is the data for a file-moved event
+func movedData() Data {
+	return Data{Name: EventFileMoved, Event: &plugin.Event{
+		Stage:  "postprocess",
+		Run:    plugin.RunInfo{ID: "abc123", Tags: []string{"sonarr"}},
+		File:   &plugin.FileInfo{Path: "/downloads/Show.Name.S01E02.mkv", Name: "Show.Name.S01E02.mkv", Destination: "/tv/Show Name/Season 1/s1e02.mkv"},
+		Tokens: map[string]string{"{showname}": "Show Name"},
+	}}
+}
+
+func TestSign(t *testing.T) {
+	//	The HMAC-SHA256 example from Wikipedia
+	got := Sign([]byte("The quick brown fox jumps over the lazy dog"), "key")
+	if want := "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"; got != want {
+		t.Errorf("Sign = %v, want %v", got, want)
+	}
+}
+
+func TestSignatureHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		secret string
+		want   string
+	}{
+		{"default header", "", "secret", "sha256=e58d457baa054b00c540babdbaa7b98884b2cfa313d6588f09319a45a03c2a71"},
+		{"custom header", "X-Hub-Signature-256", "secret", "sha256=e58d457baa054b00c540babdbaa7b98884b2cfa313d6588f09319a45a03c2a71"},
+		{"no secret", "", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, requests := server(t, http.StatusOK)
+			hook := Hook{URL: srv.URL, Body: `{"event":"{{.Name}}"}`, Secret: test.secret, SignatureHeader: test.header}
+			if _, err := hook.Send(movedData()); err != nil {
+				t.Fatal(err)
+			}
+
+			header := test.header
+			if header == "" {
+				header = DefaultSignatureHeader
+			}
+			got := requests()[0]
+			if got.body != `{"event":"file-moved"}` {
+				t.Fatalf("Body = %q, want the event", got.body)
+			}
+			if signature := got.header.Get(header); signature != test.want {
+				t.Errorf("%v = %q, want %q", header, signature, test.want)
+			}
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		retries      int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"success", []int{http.StatusOK}, 3, 1, false},
+		{"retried after a 500", []int{http.StatusInternalServerError, http.StatusOK}, 3, 2, false},
+		{"retried after a 503", []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusNoContent}, 3, 3, false},
+		{"retried after a 429", []int{http.StatusTooManyRequests, http.StatusOK}, 3, 2, false},
+		{"not retried after a 400", []int{http.StatusBadRequest, http.StatusOK}, 3, 1, true},
+		{"not retried after a 404", []int{http.StatusNotFound, http.StatusOK}, 3, 1, true},
+		{"not retried after a 401", []int{http.StatusUnauthorized, http.StatusOK}, 3, 1, true},
+		{"gives up after the retries", []int{http.StatusInternalServerError}, 2, 3, true},
+		{"no retries", []int{http.StatusInternalServerError}, 0, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, requests := server(t, test.statuses...)
+			hook := Hook{URL: srv.URL, Retries: test.retries, Backoff: "1ms"}
+
+			output, err := hook.Send(movedData())
+			if (err != nil) != test.wantErr {
+				t.Errorf("Send = %q, %v, want an error: %v", output, err, test.wantErr)
+			}
+			if got := len(requests()); got != test.wantAttempts {
+				t.Errorf("Sent %d request(s), want %d", got, test.wantAttempts)
+			}
+		})
+	}
+
+	//	Requests that can't be sent at all are retried too
+	srv, _ := server(t, http.StatusOK)
+	url := srv.URL
+	srv.Close()
+	if _, err := (Hook{URL: url, Retries: 1, Backoff: "1ms"}).Send(movedData()); err == nil {
+		t.Error("Send to a closed server worked, want an error")
+	}
+}
+
+func TestBody(t *testing.T) {
+	tests := []struct {
+		name string
+		hook Hook
+		want string
+	}{
+		{
+			"template",
+			Hook{Body: `{"text": "{{.Name}}: {{.File.Name}} to {{.File.Destination}} ({{index .Run.Tags 0}})"}`},
+			`{"text": "file-moved: Show.Name.S01E02.mkv to /tv/Show Name/Season 1/s1e02.mkv (sonarr)"}`,
+		},
+		{
+			"tokens",
+			Hook{Body: `{{token "showname"}}/{{token "{showname}"}}/{{token "missing"}}`},
+			`Show Name/Show Name/`,
+		},
+		{
+			"json function",
+			Hook{Body: `{"file": {{json .File.Name}}, "run": {{json .Run.ID}}}`},
+			`{"file": "Show.Name.S01E02.mkv", "run": "abc123"}`,
+		},
+		{
+			"no template",
+			Hook{},
+			`"event":"file-moved","stage":"postprocess"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, requests := server(t, http.StatusOK)
+			test.hook.URL = srv.URL
+			test.hook.Method = "put"
+			test.hook.Headers = map[string]string{"X-Test": "yes"}
+			if _, err := test.hook.Send(movedData()); err != nil {
+				t.Fatal(err)
+			}
+
+			got := requests()[0]
+			if !strings.Contains(got.body, test.want) || (test.hook.Body != "" && got.body != test.want) {
+				t.Errorf("Body = %q, want %q", got.body, test.want)
+			}
+			if got.method != http.MethodPut || got.header.Get("X-Test") != "yes" || got.header.Get("Content-Type") != "application/json" {
+				t.Errorf("Request = %v with headers %v, want a PUT with the hook's headers", got.method, got.header)
+			}
+		})
+	}
+
+	//	Templates that don't work are reported without sending anything
+	srv, requests := server(t, http.StatusOK)
+	for _, body := range []string{`{{.Name`, `{{.Nope.Field}}`} {
+		if _, err := (Hook{URL: srv.URL, Body: body}).Send(movedData()); err == nil || !strings.Contains(err.Error(), "webhook body") {
+			t.Errorf("Send with body %q = %v, want a body error", body, err)
+		}
+	}
+	if got := len(requests()); got != 0 {
+		t.Errorf("Sent %d request(s) with a broken template, want none", got)
+	}
+}